@@ -0,0 +1,613 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/medatechnology/suresql"
+	"github.com/medatechnology/suresql/server"
+
+	utils "github.com/medatechnology/goutil"
+	"golang.org/x/term"
+)
+
+// Exit codes of the admin and shell commands
+const (
+	EXIT_OK            = 0
+	EXIT_FAILED        = 1 // server returns error
+	EXIT_USAGE         = 2 // wrong command or arguments
+	EXIT_UNREACHABLE   = 3 // cannot reach the server
+	ADMIN_HTTP_TIMEOUT = 2 * time.Minute
+	ADMIN_TIME_FORMAT  = "2006-01-02 15:04:05"
+	ADMIN_PASSWORD_ENV = "SURESQL_USER_PASSWORD"
+)
+
+const adminUsage = `Usage: suresql admin [--json] [--url=http://host:port] <command>
+
+Commands:
+  users list [filter]                       list users, optionally username containing filter
+  users add <username> [role]               create a user, the password is asked
+  users update <username> [--new-username=] [--new-password] [--new-role=]
+  users delete <username>                   delete a user and revoke the sessions
+  sessions list [username]                  list active sessions
+  sessions revoke <username>                remove all tokens of the user
+  settings get [category] [key]             list settings, all or by category (and key)
+  settings set <category> <key> <value> [--type=string|int|float|bool]
+  settings delete <category> <key>          remove a setting, a known setting goes back to its default
+  settings known                            settings that SureSQL reads, with their type and rule
+  status                                    node status, peers health, cluster and backup
+  nodes                                     list nodes from the nodes settings
+  backup [--format=sqlite|sql]              write a backup to the server backup directory
+  reload [--env]                            read _configs and _settings again, --env also the .env files
+
+--json prints the server response as JSON instead of a table, errors are printed to stderr.
+The password of users add and --new-password is SURESQL_USER_PASSWORD, or asked (without echo) if it is
+not set, or read as one line from piped input. It is not taken from the arguments (visible in ps).
+Server is SURESQL_HOST:SURESQL_PORT (https if SURESQL_SSL=true) unless --url is set, the internal
+API path is SURESQL_INTERNAL_API and the Basic Auth credential is DBMS_USERNAME/DBMS_PASSWORD.
+Exit code is 0 on success, 1 if the server returns an error, 2 for wrong usage and 3 if the
+server cannot be reached.`
+
+// apiResponse is suresql.StandardResponse with the data kept raw, so it can be decoded per command
+type apiResponse struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type adminClient struct {
+	BaseURL  string // server URL + internal API path
+	Username string
+	Password string
+	JSON     bool
+	HTTP     *http.Client
+}
+
+// suresql admin ... , returns the exit code
+func runAdmin(args []string) int {
+	loadCLIEnv()
+
+	client := adminClient{
+		Username: utils.GetEnvString("DBMS_USERNAME", ""),
+		Password: utils.GetEnvString("DBMS_PASSWORD", ""),
+		HTTP:     &http.Client{Timeout: ADMIN_HTTP_TIMEOUT},
+	}
+	serverURL := ""
+	var params []string
+	for _, a := range args {
+		switch {
+		case a == "--json":
+			client.JSON = true
+		case strings.HasPrefix(a, "--url="):
+			serverURL = strings.TrimPrefix(a, "--url=")
+		case a == "-h" || a == "--help":
+			fmt.Println(adminUsage)
+			return EXIT_OK
+		default:
+			params = append(params, a)
+		}
+	}
+	if len(params) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return EXIT_USAGE
+	}
+
+	if serverURL == "" {
+		if serverURL = serverURLFromEnv(); serverURL == "" {
+			fmt.Fprintln(os.Stderr, "SURESQL_HOST is not set, use --url")
+			return EXIT_USAGE
+		}
+	}
+	client.BaseURL = strings.TrimSuffix(serverURL, "/") + utils.GetEnvString("SURESQL_INTERNAL_API", server.DEFAULT_INTERNAL_API)
+	if client.Username == "" || client.Password == "" {
+		fmt.Fprintln(os.Stderr, "DBMS_USERNAME or DBMS_PASSWORD is not set")
+		return EXIT_USAGE
+	}
+
+	switch params[0] {
+	case "users":
+		return client.users(params[1:])
+	case "sessions":
+		return client.sessions(params[1:])
+	case "settings":
+		return client.settings(params[1:])
+	case "status":
+		return client.status()
+	case "nodes":
+		return client.nodes()
+	case "backup":
+		return client.backup(params[1:])
+	case "reload":
+		return client.reload(params[1:])
+	}
+	fmt.Fprintln(os.Stderr, adminUsage)
+	return EXIT_USAGE
+}
+
+// Call the internal API, returns the response and the exit code. Non 2xx is printed here.
+func (c adminClient) call(method, path string, query url.Values, body interface{}) (apiResponse, int) {
+	var resp apiResponse
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot encode request:", err)
+			return resp, EXIT_FAILED
+		}
+		reader = bytes.NewReader(b)
+	}
+	fullURL := c.BaseURL + path
+	if len(query) > 0 {
+		fullURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, fullURL, reader)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot create request:", err)
+		return resp, EXIT_FAILED
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot reach server:", err)
+		return resp, EXIT_UNREACHABLE
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot read response:", err)
+		return resp, EXIT_FAILED
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		// Basic Auth failure and proxies do not answer with StandardResponse
+		resp = apiResponse{Status: res.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		if c.JSON {
+			c.printJSON(resp)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error (%d): %s\n", res.StatusCode, resp.Message)
+			if len(resp.Data) > 0 && string(resp.Data) != "null" {
+				fmt.Fprintln(os.Stderr, string(resp.Data))
+			}
+		}
+		return resp, EXIT_FAILED
+	}
+	return resp, EXIT_OK
+}
+
+// In JSON mode print the whole response and return true, caller then skips the table
+func (c adminClient) printed(resp apiResponse) bool {
+	if c.JSON {
+		c.printJSON(resp)
+	}
+	return c.JSON
+}
+
+func (c adminClient) printJSON(resp apiResponse) {
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		fmt.Println(string(resp.Data))
+		return
+	}
+	fmt.Println(string(out))
+}
+
+func (c adminClient) users(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return EXIT_USAGE
+	}
+	switch args[0] {
+	case "list":
+		query := url.Values{}
+		if len(args) > 1 {
+			query.Set("username", args[1])
+		}
+		resp, code := c.call(http.MethodGet, "/iusers", query, nil)
+		if code != EXIT_OK || c.printed(resp) {
+			return code
+		}
+		var users []server.UserTable
+		if err := json.Unmarshal(resp.Data, &users); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot decode users:", err)
+			return EXIT_FAILED
+		}
+		rows := make([][]string, 0, len(users))
+		for _, u := range users {
+			rows = append(rows, []string{strconv.Itoa(u.ID), u.Username, u.RoleName, formatTime(u.CreatedAt)})
+		}
+		printTable([]string{"ID", "USERNAME", "ROLE", "CREATED AT"}, rows)
+		return EXIT_OK
+	case "add":
+		if len(args) < 2 || len(args) > 3 {
+			fmt.Fprintln(os.Stderr, "users add needs username and optionally role, the password is not an argument")
+			return EXIT_USAGE
+		}
+		user := server.UserTable{Username: args[1]}
+		if len(args) > 2 {
+			user.RoleName = args[2]
+		}
+		password, err := readUserPassword("Password for " + user.Username + ": ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "cannot read password:", err)
+			return EXIT_USAGE
+		}
+		user.Password = password
+		return c.done(c.call(http.MethodPost, "/iusers", nil, user))
+	case "update":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "users update needs username")
+			return EXIT_USAGE
+		}
+		update := server.UserUpdateRequest{Username: args[1]}
+		for _, a := range args[2:] {
+			switch {
+			case strings.HasPrefix(a, "--new-username="):
+				update.NewUsername = strings.TrimPrefix(a, "--new-username=")
+			case a == "--new-password":
+				password, err := readUserPassword("New password for " + update.Username + ": ")
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "cannot read password:", err)
+					return EXIT_USAGE
+				}
+				update.NewPassword = password
+			case strings.HasPrefix(a, "--new-password="):
+				fmt.Fprintln(os.Stderr, "--new-password does not take a value, set "+ADMIN_PASSWORD_ENV+" or enter it when asked")
+				return EXIT_USAGE
+			case strings.HasPrefix(a, "--new-role="):
+				update.NewRoleName = strings.TrimPrefix(a, "--new-role=")
+			default:
+				fmt.Fprintln(os.Stderr, "unknown option:", a)
+				return EXIT_USAGE
+			}
+		}
+		return c.done(c.call(http.MethodPut, "/iusers", nil, update))
+	case "delete":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "users delete needs username")
+			return EXIT_USAGE
+		}
+		return c.done(c.call(http.MethodDelete, "/iusers", url.Values{"username": {args[1]}}, nil))
+	}
+	fmt.Fprintln(os.Stderr, adminUsage)
+	return EXIT_USAGE
+}
+
+func (c adminClient) sessions(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return EXIT_USAGE
+	}
+	switch args[0] {
+	case "list":
+		query := url.Values{}
+		if len(args) > 1 {
+			query.Set("username", args[1])
+		}
+		resp, code := c.call(http.MethodGet, "/sessions", query, nil)
+		if code != EXIT_OK || c.printed(resp) {
+			return code
+		}
+		var sessions []server.SessionInfo
+		if err := json.Unmarshal(resp.Data, &sessions); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot decode sessions:", err)
+			return EXIT_FAILED
+		}
+		rows := make([][]string, 0, len(sessions))
+		for _, s := range sessions {
+			rows = append(rows, []string{s.Username, s.RoleName, formatTime(s.TokenExpiresAt), formatTime(s.RefreshExpiresAt)})
+		}
+		printTable([]string{"USERNAME", "ROLE", "TOKEN EXPIRES", "REFRESH EXPIRES"}, rows)
+		return EXIT_OK
+	case "revoke":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "sessions revoke needs username")
+			return EXIT_USAGE
+		}
+		return c.done(c.call(http.MethodDelete, "/sessions", url.Values{"username": {args[1]}}, nil))
+	}
+	fmt.Fprintln(os.Stderr, adminUsage)
+	return EXIT_USAGE
+}
+
+func (c adminClient) settings(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return EXIT_USAGE
+	}
+	switch args[0] {
+	case "get":
+		query := url.Values{}
+		if len(args) > 1 {
+			query.Set("category", args[1])
+		}
+		if len(args) > 2 {
+			query.Set("key", args[2])
+		}
+		resp, code := c.call(http.MethodGet, "/settings", query, nil)
+		if code != EXIT_OK || c.printed(resp) {
+			return code
+		}
+		var settings []suresql.SettingTable
+		if err := json.Unmarshal(resp.Data, &settings); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot decode settings:", err)
+			return EXIT_FAILED
+		}
+		rows := make([][]string, 0, len(settings))
+		for _, s := range settings {
+			rows = append(rows, []string{s.Category, s.SettingKey, s.DataType, fmt.Sprintf("%v", s.GetValue())})
+		}
+		printTable([]string{"CATEGORY", "KEY", "TYPE", "VALUE"}, rows)
+		return EXIT_OK
+	case "set":
+		var params []string
+		dataType := ""
+		for _, a := range args[1:] {
+			if strings.HasPrefix(a, "--type=") {
+				dataType = strings.TrimPrefix(a, "--type=")
+				continue
+			}
+			params = append(params, a)
+		}
+		if len(params) != 3 {
+			fmt.Fprintln(os.Stderr, "settings set needs category, key and value")
+			return EXIT_USAGE
+		}
+		return c.done(c.call(http.MethodPut, "/settings", nil, c.newSetting(params[0], params[1], params[2], dataType)))
+	case "delete":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "settings delete needs category and key")
+			return EXIT_USAGE
+		}
+		return c.done(c.call(http.MethodDelete, "/settings", url.Values{"category": {args[1]}, "key": {args[2]}}, nil))
+	case "known":
+		resp, code := c.call(http.MethodGet, "/settings/known", nil, nil)
+		if code != EXIT_OK || c.printed(resp) {
+			return code
+		}
+		var known []suresql.KnownSetting
+		if err := json.Unmarshal(resp.Data, &known); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot decode known settings:", err)
+			return EXIT_FAILED
+		}
+		rows := make([][]string, 0, len(known))
+		for _, k := range known {
+			rule := strings.Join(k.Options, "|")
+			if k.DataType == suresql.SETTING_TYPE_INT {
+				rule = fmt.Sprintf(">= %d", k.Min)
+			}
+			rows = append(rows, []string{k.Category, k.SettingKey, k.DataType, rule, k.Description})
+		}
+		printTable([]string{"CATEGORY", "KEY", "TYPE", "RULE", "DESCRIPTION"}, rows)
+		return EXIT_OK
+	}
+	fmt.Fprintln(os.Stderr, adminUsage)
+	return EXIT_USAGE
+}
+
+// Build the setting from the command line value, the server converts it to the type. Without --type the
+// type of the existing setting is used, the server uses the type of the known keys or string for a new one.
+func (c adminClient) newSetting(category, key, value, dataType string) suresql.SettingTable {
+	setting := suresql.SettingTable{Category: category, SettingKey: key, DataType: dataType, TextValue: value}
+	if dataType == "" {
+		resp, code := c.call(http.MethodGet, "/settings", url.Values{"category": {category}, "key": {key}}, nil)
+		var existing []suresql.SettingTable
+		if code == EXIT_OK && json.Unmarshal(resp.Data, &existing) == nil && len(existing) > 0 {
+			setting.DataType = existing[0].DataType
+		}
+	}
+	return setting
+}
+
+func (c adminClient) status() int {
+	resp, code := c.call(http.MethodGet, "/dbms_status", nil, nil)
+	if code != EXIT_OK || c.printed(resp) {
+		return code
+	}
+	var status suresql.NodeStatusResponse
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot decode status:", err)
+		return EXIT_FAILED
+	}
+	printNodeStatus(status)
+	return EXIT_OK
+}
+
+// Also used by the shell .status
+func printNodeStatus(status suresql.NodeStatusResponse) {
+	status.StatusStruct.PrintPretty("", "Status")
+	for _, p := range status.Peers {
+		p.PrintPretty("  ", fmt.Sprintf("Peer %d", p.NodeNumber))
+	}
+
+	if len(status.PeersHealth) > 0 {
+		fmt.Println()
+		rows := make([][]string, 0, len(status.PeersHealth))
+		for _, p := range status.PeersHealth {
+			up := "down"
+			if p.IsUp {
+				up = "up"
+			}
+			rows = append(rows, []string{strconv.Itoa(p.NodeNumber), p.URL, p.Mode, up, p.Latency.String(), p.LastError})
+		}
+		printTable([]string{"NODE", "URL", "MODE", "HEALTH", "LATENCY", "ERROR"}, rows)
+	}
+	if status.Cluster != nil {
+		fmt.Println()
+		fmt.Println("Cluster:", status.Cluster.String())
+	}
+	if status.Backup != nil {
+		fmt.Println()
+		fmt.Printf("Backup every %s, last success %s %s\n", status.Backup.Interval, formatTime(status.Backup.LastSuccess), status.Backup.LastFile)
+		if status.Backup.LastError != "" {
+			fmt.Printf("Backup failed %d times: %s\n", status.Backup.ConsecutiveFailures, status.Backup.LastError)
+		}
+	}
+}
+
+func (c adminClient) nodes() int {
+	resp, code := c.call(http.MethodGet, "/nodes", nil, nil)
+	if code != EXIT_OK || c.printed(resp) {
+		return code
+	}
+	var nodes []suresql.NodeSetting
+	if err := json.Unmarshal(resp.Data, &nodes); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot decode nodes:", err)
+		return EXIT_FAILED
+	}
+	rows := make([][]string, 0, len(nodes))
+	for _, n := range nodes {
+		this := ""
+		if n.IsThisNode {
+			this = "*"
+		}
+		rows = append(rows, []string{strconv.Itoa(n.NodeNumber), n.Key, n.Hostname, n.IP, n.Mode, this})
+	}
+	printTable([]string{"NODE", "KEY", "HOSTNAME", "IP", "MODE", "THIS"}, rows)
+	return EXIT_OK
+}
+
+func (c adminClient) backup(args []string) int {
+	query := url.Values{"save": {"true"}}
+	for _, a := range args {
+		if !strings.HasPrefix(a, "--format=") {
+			fmt.Fprintln(os.Stderr, "unknown option:", a)
+			return EXIT_USAGE
+		}
+		query.Set("format", strings.TrimPrefix(a, "--format="))
+	}
+	resp, code := c.call(http.MethodGet, "/backup", query, nil)
+	if code != EXIT_OK || c.printed(resp) {
+		return code
+	}
+	var result suresql.BackupResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot decode backup result:", err)
+		return EXIT_FAILED
+	}
+	fmt.Printf("Backup saved to %s (%d bytes, %.0fms)\n", result.File, result.Size, result.Duration)
+	return EXIT_OK
+}
+
+func (c adminClient) reload(args []string) int {
+	query := url.Values{}
+	for _, a := range args {
+		if a != "--env" {
+			fmt.Fprintln(os.Stderr, "unknown option:", a)
+			return EXIT_USAGE
+		}
+		query.Set("env", "true")
+	}
+	resp, code := c.call(http.MethodPost, "/reload", query, nil)
+	if code != EXIT_OK || c.printed(resp) {
+		return code
+	}
+	var result suresql.ReloadResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		fmt.Fprintln(os.Stderr, "cannot decode reload result:", err)
+		return EXIT_FAILED
+	}
+	fmt.Printf("Reloaded in %.0fms, config changed: %t\n", result.Duration, result.ConfigChanged)
+	for _, key := range result.Changed {
+		fmt.Println("  changed:", key)
+	}
+	return EXIT_OK
+}
+
+// For commands that only need the message of the response
+func (c adminClient) done(resp apiResponse, code int) int {
+	if code != EXIT_OK || c.printed(resp) {
+		return code
+	}
+	fmt.Println(resp.Message)
+	return EXIT_OK
+}
+
+// Password from SURESQL_USER_PASSWORD, asked without echo on a terminal (the prompt goes to stderr so it is
+// not in the --json output) or one line of piped input
+func readUserPassword(prompt string) (string, error) {
+	if password := os.Getenv(ADMIN_PASSWORD_ENV); password != "" {
+		return password, nil
+	}
+	var password string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		password = string(b)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password = line
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
+
+// Only the files that exist, so the missing file message does not break the --json output
+func loadCLIEnv() {
+	var envFiles []string
+	for _, f := range []string{".env.dev", suresql.SURESQL_ENV_FILE} {
+		if _, err := os.Stat(f); err == nil {
+			envFiles = append(envFiles, f)
+		}
+	}
+	utils.ReloadEnvEach(envFiles...)
+}
+
+// SureSQL server URL from SURESQL_HOST, SURESQL_PORT and SURESQL_SSL, empty if host is not set
+func serverURLFromEnv() string {
+	host := utils.GetEnvString("SURESQL_HOST", "")
+	if host == "" {
+		return ""
+	}
+	scheme := "http"
+	if utils.GetEnvBool("SURESQL_SSL", false) {
+		scheme = "https"
+	}
+	serverURL := scheme + "://" + host
+	if port := utils.GetEnvString("SURESQL_PORT", ""); port != "" {
+		serverURL += ":" + port
+	}
+	return serverURL
+}
+
+func printTable(headers []string, rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+	w.Flush()
+	fmt.Printf("(%d rows)\n", len(rows))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(ADMIN_TIME_FORMAT)
+}
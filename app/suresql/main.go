@@ -1,6 +1,10 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/medatechnology/suresql"
 	"github.com/medatechnology/suresql/server"
 
@@ -9,21 +13,54 @@ import (
 
 // SureSQL BackEnd Service
 func main() {
+	// Subcommands, without subcommand it is the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "shell" {
+		os.Exit(runShell(os.Args[2:]))
+	}
+
 	err := suresql.ConnectInternal()
 	if err != nil {
-		// Cannot connect to DBMS, exit the app
-		// TODO: maybe make this to just wait for 30sec then try again and keep looping like that
-		// .     add the middleware CheckConnected and if false return "DBMS not connected" for all API
-		simplelog.LogErrorStr("sureSQL", err, "Cannot connect to internal rqlite engine")
-		return
+		// Start anyway, the APIs answer "DBMS not connected" until the connection keeper connects
+		simplelog.LogErrorStr("sureSQL", err, "Cannot connect to internal rqlite engine, retrying in the background")
+		suresql.PrepareDisconnected()
 	}
 
 	// Prepare the SureSQL
-	server := server.CreateServer(suresql.CurrentNode)
+	srv := server.CreateServer(suresql.CurrentNode)
 
 	suresql.CurrentNode.PrintWelcomePretty()
-	// Start SureSQL server
-	if err := server.Start(""); err != nil {
-		simplelog.LogErrorStr("main", err, "cannot start SureSQL")
+	// Retry the DBMS connection if it failed, and check it is still there
+	suresql.CurrentNode.StartConnectionKeeper()
+	// Check the peers in the background
+	suresql.CurrentNode.StartHealthProber()
+	// Backup to the backup directory if the schedule is set
+	suresql.CurrentNode.StartBackupScheduler()
+	// Read the settings again if the reload interval is set
+	suresql.CurrentNode.StartReloadPoller()
+	// Start SureSQL server, Start returns when it is shut down
+	started := make(chan error, 1)
+	go func() {
+		started <- srv.Start("")
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-started:
+		if err != nil {
+			simplelog.LogErrorStr("main", err, "cannot start SureSQL")
+		}
+		return
+	case sig := <-quit:
+		simplelog.LogFormat("received %s, shutting down", sig)
+	}
+	if err := server.Shutdown(srv, server.ShutdownTimeout()); err != nil {
+		os.Exit(1)
 	}
 }
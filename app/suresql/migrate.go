@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/medatechnology/suresql"
+
+	orm "github.com/medatechnology/simpleorm"
+)
+
+const migrateUsage = `Usage: suresql migrate <command> [--dry-run] [--dir=migrations/]
+
+Commands:
+  status          list migrations and whether they are applied
+  up              apply all new migrations
+  down [N]        rollback the last N applied migrations (default 1)
+  redo            rollback the last migration then apply it again
+  create <name>   create empty up and down files with the next version
+
+--dry-run prints the SQL commands without executing them.
+The DBMS is the one configured in the environment (DBMS_*), same as the server.`
+
+// suresql migrate ... , returns the exit code
+func runMigrate(args []string) int {
+	dryRun := false
+	dir := suresql.MIGRATION_DIRECTORY
+	var params []string
+	for _, a := range args {
+		switch {
+		case a == "--dry-run":
+			dryRun = true
+		case strings.HasPrefix(a, "--dir="):
+			dir = strings.TrimPrefix(a, "--dir=")
+			if !strings.HasSuffix(dir, "/") {
+				dir += "/"
+			}
+		case a == "-h" || a == "--help":
+			fmt.Println(migrateUsage)
+			return 0
+		default:
+			params = append(params, a)
+		}
+	}
+	if len(params) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	// create does not need the DBMS
+	if params[0] == "create" {
+		if len(params) < 2 {
+			fmt.Println("migration name is required")
+			return 2
+		}
+		up, down, err := suresql.CreateMigrationFiles(dir, strings.Join(params[1:], " "))
+		if err != nil {
+			fmt.Println("cannot create migration:", err)
+			return 1
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return 0
+	}
+
+	if err := suresql.ConnectDBMS(); err != nil {
+		fmt.Println("cannot connect to DBMS:", err)
+		return 1
+	}
+	list, err := suresql.ReadMigrationFiles(dir)
+	if err != nil {
+		fmt.Println("cannot read migrations:", err)
+		return 1
+	}
+	// _configs tells if the DB was initialized before _migrations, without it the DB is new (or unreachable)
+	if err := suresql.LoadConfigFromDB(&suresql.CurrentNode.InternalConnection); err != nil {
+		if _, serr := suresql.CurrentNode.InternalConnection.Status(); serr != nil {
+			fmt.Println("cannot connect to DBMS:", serr)
+			return 1
+		}
+	}
+	migrator := suresql.CurrentNode.InternalMigrator()
+	if dryRun || params[0] == "status" {
+		// Nothing is written, the baseline versions of an old DB are only taken as applied
+		migrator.AssumedBaseline = suresql.InternalBaselineVersion()
+	} else if err := suresql.BaselineInternal(migrator, list); err != nil {
+		// Same as the node start, the baseline versions of an old DB are recorded instead of run again
+		fmt.Println("cannot record baseline migrations:", err)
+		return 1
+	}
+
+	switch params[0] {
+	case "status":
+		status, err := migrator.Status(list)
+		if err != nil {
+			fmt.Println("cannot get migration status:", err)
+			return 1
+		}
+		printMigrationStatus(status)
+	case "up":
+		return migrateStep(migrator, list, suresql.MIGRATION_UP, 0, dryRun)
+	case "down":
+		n := 1
+		if len(params) > 1 {
+			if n, err = strconv.Atoi(params[1]); err != nil || n < 1 {
+				fmt.Println("down needs a positive number")
+				return 2
+			}
+		}
+		return migrateStep(migrator, list, suresql.MIGRATION_DOWN, n, dryRun)
+	case "redo":
+		if dryRun {
+			down, up, err := migrator.PlanRedo(list)
+			if err != nil {
+				fmt.Println("cannot plan migration:", err)
+				return 1
+			}
+			printMigrationPlan(down, suresql.MIGRATION_DOWN)
+			printMigrationPlan(up, suresql.MIGRATION_UP)
+			return 0
+		}
+		if code := migrateStep(migrator, list, suresql.MIGRATION_DOWN, 1, dryRun); code != 0 {
+			return code
+		}
+		return migrateStep(migrator, list, suresql.MIGRATION_UP, 0, dryRun)
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+func migrateStep(migrator suresql.Migrator, list []suresql.Migration, direction string, n int, dryRun bool) int {
+	if dryRun {
+		plan, err := migrator.Plan(list, direction, n)
+		if err != nil {
+			fmt.Println("cannot plan migration:", err)
+			return 1
+		}
+		printMigrationPlan(plan, direction)
+		return 0
+	}
+
+	var done []suresql.Migration
+	var err error
+	if direction == suresql.MIGRATION_DOWN {
+		done, err = migrator.Down(list, n)
+	} else {
+		done, err = migrator.Up(list)
+	}
+	for _, m := range done {
+		fmt.Printf("%s %s_%s\n", direction, m.Version, m.Name)
+	}
+	if err != nil {
+		fmt.Println("migration failed:", err)
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to migrate", direction)
+	}
+	return 0
+}
+
+// SQL commands of the plan, for --dry-run
+func printMigrationPlan(plan []suresql.Migration, direction string) {
+	if len(plan) == 0 {
+		fmt.Println("nothing to migrate", direction)
+	}
+	for _, m := range plan {
+		lines := m.Up
+		if direction == suresql.MIGRATION_DOWN {
+			lines = m.Down
+		}
+		fmt.Printf("-- %s_%s (%s)\n", m.Version, m.Name, direction)
+		for _, c := range orm.ConvertSQLCommands(lines) {
+			fmt.Println(c + ";")
+		}
+	}
+}
+
+func printMigrationStatus(status []suresql.Migration) {
+	fmt.Printf("%-8s %-30s %-8s %-20s %s\n", "VERSION", "NAME", "APPLIED", "APPLIED AT", "NOTE")
+	for _, m := range status {
+		applied, at, note := "no", "", ""
+		if m.Applied {
+			applied = "yes"
+			if !m.AppliedAt.IsZero() {
+				at = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+		}
+		switch {
+		case m.Drift:
+			note = "changed after applied"
+		case m.Missing:
+			note = "file is missing"
+		case !m.HasDown:
+			note = "no down file"
+		}
+		fmt.Printf("%-8s %-30s %-8s %-20s %s\n", m.Version, m.Name, applied, at, note)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/medatechnology/suresql"
+	"github.com/medatechnology/suresql/client"
+
+	utils "github.com/medatechnology/goutil"
+	orm "github.com/medatechnology/simpleorm"
+	"golang.org/x/term"
+)
+
+const (
+	SHELL_PROMPT          = "suresql> "
+	SHELL_PROMPT_CONTINUE = "     ...> "
+	SHELL_HISTORY_SHOW    = 20               // entries printed by .history
+	SHELL_REFRESH_BEFORE  = 30 * time.Second // refresh the token this long before it expires
+	SHELL_HTTP_TIMEOUT    = 2 * time.Minute
+	SHELL_NULL            = "NULL"
+)
+
+const shellUsage = `Usage: suresql shell [--url=http://host:port] [--user=username] [--password=password]
+                     [--api-key=key] [--client-id=id]
+
+Interactive SQL shell over the SureSQL API. Statements end with ; (not inside quotes or comments) and
+can span multiple lines, up/down recalls the previous lines. SELECT (and WITH, PRAGMA, EXPLAIN) goes to /db/api/querysql, the rest to /db/api/sql.
+Server is SURESQL_HOST:SURESQL_PORT unless --url is set, API key and client ID are SURESQL_API_KEY
+and SURESQL_CLIENT_ID. The password is SURESQL_PASSWORD, or asked (without echo) if it is not set.
+--password also works but it is visible in the process list.
+Input can also be piped: echo "SELECT 1;" | SURESQL_PASSWORD=secret suresql shell --user=john`
+
+const shellHelp = `.tables           list tables and views
+.schema <table>   show CREATE statement of the table
+.status           node status
+.history          last statements of this session
+.help             this help
+.quit             exit the shell (also .exit or Ctrl-D)`
+
+// Query statements are sent to /querysql, everything else to /sql
+var shellQueryPrefixes = []string{"SELECT", "WITH", "PRAGMA", "EXPLAIN", "VALUES"}
+
+// shell uses the client SDK, numbers are kept as json.Number so integers are printed as is
+type shellClient struct {
+	*client.Client
+}
+
+// suresql shell ... , returns the exit code
+func runShell(args []string) int {
+	loadCLIEnv()
+	config := client.Config{
+		APIKey:    utils.GetEnvString("SURESQL_API_KEY", ""),
+		ClientID:  utils.GetEnvString("SURESQL_CLIENT_ID", ""),
+		Password:  utils.GetEnvString("SURESQL_PASSWORD", ""),
+		Timeout:   SHELL_HTTP_TIMEOUT,
+		UseNumber: true,
+	}
+	serverURL := ""
+	for _, a := range args {
+		switch {
+		case strings.HasPrefix(a, "--url="):
+			serverURL = strings.TrimPrefix(a, "--url=")
+		case strings.HasPrefix(a, "--user="):
+			config.Username = strings.TrimPrefix(a, "--user=")
+		case strings.HasPrefix(a, "--password="):
+			config.Password = strings.TrimPrefix(a, "--password=")
+		case strings.HasPrefix(a, "--api-key="):
+			config.APIKey = strings.TrimPrefix(a, "--api-key=")
+		case strings.HasPrefix(a, "--client-id="):
+			config.ClientID = strings.TrimPrefix(a, "--client-id=")
+		case a == "-h" || a == "--help":
+			fmt.Println(shellUsage)
+			return EXIT_OK
+		default:
+			fmt.Println("unknown option:", a)
+			fmt.Println(shellUsage)
+			return EXIT_USAGE
+		}
+	}
+	if serverURL == "" {
+		if serverURL = serverURLFromEnv(); serverURL == "" {
+			fmt.Println("SURESQL_HOST is not set, use --url")
+			return EXIT_USAGE
+		}
+	}
+	config.URL = serverURL
+
+	// Prompts are only printed for a terminal, piped input prints the results only
+	input := newShellInput()
+	interactive := input.Interactive()
+	if config.Username == "" {
+		config.Username, _ = input.ReadLine("Username: ")
+		config.Username = strings.TrimSpace(config.Username)
+	}
+	if config.Password == "" {
+		config.Password = input.ReadPassword("Password: ")
+	}
+
+	conn, err := client.Connect(config)
+	if err != nil {
+		fmt.Println("cannot connect:", err)
+		return EXIT_UNREACHABLE
+	}
+	shell := shellClient{conn}
+	if interactive {
+		fmt.Printf("Connected to %s as %s, .help for commands\n", config.URL, config.Username)
+	}
+
+	history := &shellHistory{}
+	failed := false
+	// Text after the last ; , it can end inside a quote or a comment that continues on the next line
+	pending := ""
+	for {
+		prompt := SHELL_PROMPT
+		if pending != "" {
+			prompt = SHELL_PROMPT_CONTINUE
+		}
+		line, err := input.ReadLine(prompt)
+		if err != nil {
+			if pending != "" {
+				// Last statement without ; at the end of the input
+				statements, _ := splitStatements(pending, true)
+				failed = !shell.run(statements) || failed
+				history.Add(pending)
+			}
+			if interactive {
+				fmt.Println()
+			}
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+
+		// Dot-commands are one line and only when no statement is pending
+		if pending == "" && strings.HasPrefix(trimmed, ".") {
+			history.Add(trimmed)
+			quit, ok := shell.dotCommand(trimmed, history)
+			failed = !ok || failed
+			if quit {
+				break
+			}
+			continue
+		}
+		if trimmed == "" && pending == "" {
+			continue
+		}
+		text := line
+		if pending != "" {
+			text = pending + "\n" + line
+		}
+		statements, rest := splitStatements(text, false)
+		pending = rest
+		if len(statements) == 0 {
+			continue
+		}
+		history.Add(text[:len(text)-len(rest)])
+		failed = !shell.run(statements) || failed
+	}
+
+	// For piped input the exit code tells if any statement failed
+	if failed && !interactive {
+		return EXIT_FAILED
+	}
+	return EXIT_OK
+}
+
+// Input lines of the shell. On a terminal it is a line editor (term.Terminal) with recall of the previous
+// lines, the terminal is only in raw mode while a line is read so the results are printed as usual.
+type shellInput struct {
+	fd       int
+	terminal *term.Terminal
+	reader   *bufio.Reader // piped input
+}
+
+func newShellInput() *shellInput {
+	in := &shellInput{fd: int(os.Stdin.Fd())}
+	if term.IsTerminal(in.fd) {
+		in.terminal = term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "")
+	} else {
+		in.reader = bufio.NewReader(os.Stdin)
+	}
+	return in
+}
+
+func (in *shellInput) Interactive() bool {
+	return in.terminal != nil
+}
+
+// Returns io.EOF at the end of the input, Ctrl-D on an empty line or Ctrl-C
+func (in *shellInput) ReadLine(prompt string) (string, error) {
+	if in.terminal == nil {
+		line, err := in.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	state, err := term.MakeRaw(in.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(in.fd, state)
+	if width, height, err := term.GetSize(in.fd); err == nil && width > 0 {
+		in.terminal.SetSize(width, height)
+	}
+	in.terminal.SetPrompt(prompt)
+	line, err := in.terminal.ReadLine()
+	if err == term.ErrPasteIndicator {
+		err = nil
+	}
+	return line, err
+}
+
+// Not echoed on a terminal, piped input is read as a line
+func (in *shellInput) ReadPassword(prompt string) string {
+	if in.terminal == nil {
+		line, _ := in.ReadLine(prompt)
+		return strings.TrimSpace(line)
+	}
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(in.fd)
+	fmt.Println()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(password))
+}
+
+// Returns quit, ok
+func (c *shellClient) dotCommand(line string, history *shellHistory) (bool, bool) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".quit", ".exit":
+		return true, true
+	case ".help":
+		fmt.Println(shellHelp)
+	case ".tables":
+		return false, c.query(orm.ParametereizedSQL{
+			Query: "SELECT name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name",
+		})
+	case ".schema":
+		if len(fields) < 2 {
+			fmt.Println(".schema needs the table name")
+			return false, false
+		}
+		return false, c.schema(fields[1])
+	case ".status":
+		return false, c.status()
+	case ".history":
+		history.Print(SHELL_HISTORY_SHOW)
+	default:
+		fmt.Printf("unknown command %s, .help for commands\n", fields[0])
+		return false, false
+	}
+	return false, true
+}
+
+// Run the statements, returns false if any failed
+func (c *shellClient) run(statements []string) bool {
+	ok := true
+	for _, statement := range statements {
+		if isQueryStatement(statement) {
+			ok = c.query(orm.ParametereizedSQL{Query: statement}) && ok
+		} else {
+			ok = c.exec(statement) && ok
+		}
+	}
+	return ok
+}
+
+func (c *shellClient) query(sql orm.ParametereizedSQL) bool {
+	req := suresql.SQLRequest{IncludeColumns: true}
+	if len(sql.Values) > 0 {
+		req.ParamSQL = []orm.ParametereizedSQL{sql}
+	} else {
+		req.Statements = []string{sql.Query}
+	}
+	results, err := c.QuerySQLRequest(req)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if len(results) == 0 {
+		fmt.Println("(0 rows)")
+		return true
+	}
+	for _, r := range results {
+		printRecords(r.Columns, r.Records)
+		note := ""
+		if r.Truncated {
+			note = ", truncated by the server limit"
+		}
+		fmt.Printf("(%d rows, %.2fms%s)\n", r.Count, r.ExecutionTime, note)
+	}
+	return true
+}
+
+func (c *shellClient) exec(statement string) bool {
+	resp, err := c.Exec(statement)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	fmt.Printf("OK, %d rows affected (%.2fms)\n", resp.RowsAffected, resp.ExecutionTime)
+	return true
+}
+
+func (c *shellClient) schema(table string) bool {
+	results, err := c.QuerySQLParams(orm.ParametereizedSQL{
+		Query:  "SELECT sql FROM sqlite_master WHERE name = ? AND sql IS NOT NULL",
+		Values: []interface{}{table},
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	if len(results) == 0 || len(results[0].Records) == 0 {
+		fmt.Println("table", table, "not found")
+		return false
+	}
+	for _, r := range results[0].Records {
+		fmt.Println(formatValue(r.Data["sql"]) + ";")
+	}
+	return true
+}
+
+func (c *shellClient) status() bool {
+	status, err := c.Status()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+	printNodeStatus(status)
+	return true
+}
+
+// Split by ; that is not inside quotes (' " ` and [ ]) or comments (-- and /* */), the comments are
+// removed and empty statements are skipped. Returns the text after the last ; as rest if it has more than
+// spaces and comments or ends inside a quote or block comment, the next line is added to it. With final the
+// rest is returned as the last statement.
+func splitStatements(text string, final bool) ([]string, string) {
+	var list []string
+	var current strings.Builder
+	var quote rune
+	lineComment, blockComment := false, false
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		var next byte
+		if i+size < len(text) {
+			next = text[i+size]
+		}
+		switch {
+		case lineComment:
+			if r == '\n' {
+				lineComment = false
+				current.WriteRune(r)
+			}
+		case blockComment:
+			if r == '*' && next == '/' {
+				blockComment = false
+				current.WriteRune(' ')
+				size++
+			}
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '-' && next == '-':
+			lineComment = true
+			size++
+		case r == '/' && next == '*':
+			blockComment = true
+			size++
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			current.WriteRune(r)
+		case r == '[':
+			quote = ']'
+			current.WriteRune(r)
+		case r == ';':
+			if s := strings.TrimSpace(current.String()); s != "" {
+				list = append(list, s)
+			}
+			current.Reset()
+			start = i + size
+		default:
+			current.WriteRune(r)
+		}
+		i += size
+	}
+	rest := strings.TrimSpace(current.String())
+	if final {
+		if rest != "" {
+			list = append(list, rest)
+		}
+		return list, ""
+	}
+	if rest == "" && quote == 0 && !blockComment {
+		return list, ""
+	}
+	return list, text[start:]
+}
+
+func isQueryStatement(statement string) bool {
+	upper := strings.ToUpper(strings.TrimLeft(statement, " \t\r\n("))
+	for _, p := range shellQueryPrefixes {
+		if strings.HasPrefix(upper, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print the records as aligned table, the column order is from the columns metadata
+func printRecords(columns []suresql.ColumnInfo, records []orm.DBRecord) {
+	if len(records) == 0 {
+		return
+	}
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].Order < columns[j].Order })
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range columns {
+		names = append(names, c.Name)
+		seen[c.Name] = true
+	}
+	// Metadata is optional, columns that are not in it are added by name
+	var extra []string
+	for k := range records[0].Data {
+		if !seen[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	widths := make([]int, len(names))
+	rows := make([][]string, len(records))
+	for i, n := range names {
+		widths[i] = utf8.RuneCountInString(n)
+	}
+	for r, rec := range records {
+		rows[r] = make([]string, len(names))
+		for i, n := range names {
+			v := formatValue(rec.Data[n])
+			rows[r][i] = v
+			if w := utf8.RuneCountInString(v); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	border := "+"
+	for _, w := range widths {
+		border += strings.Repeat("-", w+2) + "+"
+	}
+	printRow := func(values []string) {
+		line := "|"
+		for i, v := range values {
+			line += " " + v + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)) + " |"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(border)
+	printRow(names)
+	fmt.Println(border)
+	for _, r := range rows {
+		printRow(r)
+	}
+	fmt.Println(border)
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return SHELL_NULL
+	case string:
+		// Keep the table in one line per row
+		return strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(val)
+	case json.Number:
+		return val.String()
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// Statements and dot-commands of this session for .history, the line editor has its own recall
+type shellHistory struct {
+	Entries []string
+}
+
+func (h *shellHistory) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || (len(h.Entries) > 0 && h.Entries[len(h.Entries)-1] == entry) {
+		return
+	}
+	h.Entries = append(h.Entries, entry)
+}
+
+func (h *shellHistory) Print(n int) {
+	start := len(h.Entries) - n
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(h.Entries); i++ {
+		fmt.Printf("%4d  %s\n", i+1, strings.ReplaceAll(h.Entries[i], "\n", "\n      "))
+	}
+}
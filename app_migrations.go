@@ -0,0 +1,218 @@
+package suresql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/object"
+)
+
+// Schema migrations of the client applications. Scripts are uploaded with /db/api/migrations and stored
+// in APP_MIGRATION_SCRIPT_TABLE, applied versions are tracked per app in APP_MIGRATION_TABLE (not _migrations).
+const (
+	APP_MIGRATION_TABLE = "_app_migrations"
+)
+
+var (
+	appNamePattern     = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	appVersionPattern  = regexp.MustCompile(`^[0-9]{1,20}$`)
+	appMigrationPrefix = "_" // tables of SureSQL start with _, app migrations cannot change them
+)
+
+// Uploaded up/down script of an app
+type AppMigrationTable struct {
+	App       string `json:"app"                   db:"app"`
+	Version   string `json:"version"               db:"version"`
+	Name      string `json:"name"                  db:"name"`
+	UpSQL     string `json:"up_sql"                db:"up_sql"`
+	DownSQL   string `json:"down_sql,omitempty"    db:"down_sql"`
+	CreatedAt string `json:"created_at,omitempty"  db:"created_at"`
+}
+
+func (a AppMigrationTable) TableName() string {
+	return "_app_migration_scripts"
+}
+
+func (a AppMigrationTable) ToMigration() Migration {
+	up := strings.Split(a.UpSQL, "\n")
+	m := Migration{Version: a.Version, Name: a.Name, Up: up, Checksum: MigrationChecksum(up)}
+	if strings.TrimSpace(a.DownSQL) != "" {
+		m.Down = strings.Split(a.DownSQL, "\n")
+		m.HasDown = true
+	}
+	return m
+}
+
+// Owner of the app migrations, the first upload makes the caller the owner. The owner is the user, or any
+// user of the role if OwnerRole is set.
+type AppOwnerTable struct {
+	App       string `json:"app"                   db:"app"`
+	OwnerUser string `json:"owner_user,omitempty"  db:"owner_user"`
+	OwnerRole string `json:"owner_role,omitempty"  db:"owner_role"`
+	CreatedAt string `json:"created_at,omitempty"  db:"created_at"`
+}
+
+func (a AppOwnerTable) TableName() string {
+	return "_app_owners"
+}
+
+func (a AppOwnerTable) IsOwner(username, role string) bool {
+	if a.OwnerRole != "" {
+		return a.OwnerRole == role
+	}
+	return a.OwnerUser != "" && a.OwnerUser == username
+}
+
+// Version is a number so the order is the same as the internal files (ie: 00001 or 20240101120000)
+func (a AppMigrationTable) Validate() error {
+	if !appNamePattern.MatchString(a.App) {
+		return fmt.Errorf("app must be 1-64 letters, numbers, _ or -")
+	}
+	if !appVersionPattern.MatchString(a.Version) {
+		return fmt.Errorf("version must be a number, ie: 00001")
+	}
+	if strings.TrimSpace(a.UpSQL) == "" {
+		return fmt.Errorf("up_sql is required")
+	}
+	for _, c := range append(orm.ConvertSQLCommands(strings.Split(a.UpSQL, "\n")), orm.ConvertSQLCommands(strings.Split(a.DownSQL, "\n"))...) {
+		if touchesInternalTable(c) {
+			return fmt.Errorf("app migration cannot change SureSQL tables (%s*): %s", appMigrationPrefix, c)
+		}
+	}
+	return nil
+}
+
+// Any identifier starting with _ (SureSQL internal tables) in the statement, so schema qualified (main._users)
+// and quoted ("main"."_settings") names are caught as well. String literals and comments are skipped.
+func touchesInternalTable(command string) bool {
+	for i := 0; i < len(command); {
+		c := command[i]
+		switch {
+		case c == '\'':
+			// string literal, '' is the escaped quote
+			for i++; i < len(command); i++ {
+				if command[i] == '\'' {
+					if i+1 < len(command) && command[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+		case c == '-' && i+1 < len(command) && command[i+1] == '-':
+			for i < len(command) && command[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '`' || c == '[':
+			end := byte(c)
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(command[i+1:], end)
+			if j < 0 {
+				j = len(command) - i - 1
+			}
+			if strings.HasPrefix(strings.TrimSpace(command[i+1:i+1+j]), appMigrationPrefix) {
+				return true
+			}
+			i += j + 2
+		case isIdentifierChar(c):
+			j := i
+			for j < len(command) && isIdentifierChar(command[j]) {
+				j++
+			}
+			if strings.HasPrefix(command[i:j], appMigrationPrefix) {
+				return true
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Migrator for the app on the connection of the caller (the user's routed connection), one transaction
+// per version
+func (n SureSQLNode) AppMigrator(app string, db SureSQLDB) Migrator {
+	return Migrator{DB: db, Table: APP_MIGRATION_TABLE, App: app}
+}
+
+// Returns ErrAppNotOwner if the caller is not the owner of the app. The app without owner gets the caller
+// as owner if claim is set (the role if byRole), otherwise everyone can read it.
+func (n SureSQLNode) AuthorizeAppOwner(app, username, role string, claim, byRole bool) error {
+	owner, err := n.AppOwner(app)
+	if err == orm.ErrSQLNoRows {
+		if !claim {
+			return nil
+		}
+		owner = AppOwnerTable{App: app, OwnerUser: username}
+		if byRole {
+			owner = AppOwnerTable{App: app, OwnerRole: role}
+		}
+		res := n.InternalConnection.ExecOneSQLParameterized(orm.ParametereizedSQL{
+			Query:  fmt.Sprintf("INSERT OR IGNORE INTO %s (app, owner_user, owner_role) VALUES (?, ?, ?)", owner.TableName()),
+			Values: []interface{}{owner.App, owner.OwnerUser, owner.OwnerRole},
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		// Another caller can claim it at the same time, the one in the table is the owner
+		owner, err = n.AppOwner(app)
+	}
+	if err != nil {
+		return err
+	}
+	if !owner.IsOwner(username, role) {
+		return ErrAppNotOwner
+	}
+	return nil
+}
+
+func (n SureSQLNode) AppOwner(app string) (AppOwnerTable, error) {
+	record, err := n.InternalConnection.SelectOneWithCondition(AppOwnerTable{}.TableName(), &orm.Condition{Field: "app", Operator: "=", Value: app})
+	if err != nil {
+		return AppOwnerTable{}, err
+	}
+	return object.MapToStruct[AppOwnerTable](record.Data), nil
+}
+
+// Uploaded scripts of the app ordered by version
+func (n SureSQLNode) AppMigrations(app string) ([]Migration, error) {
+	records, err := n.InternalConnection.SelectManyWithCondition(AppMigrationTable{}.TableName(), &orm.Condition{Field: "app", Operator: "=", Value: app})
+	if err != nil && err != orm.ErrSQLNoRows {
+		return nil, err
+	}
+	var list []Migration
+	for _, r := range records {
+		list = append(list, object.MapToStruct[AppMigrationTable](r.Data).ToMigration())
+	}
+	sortMigrations(list)
+	return list, nil
+}
+
+// Save the uploaded script, a version that is already applied cannot be changed
+func (n SureSQLNode) SaveAppMigration(script AppMigrationTable) error {
+	status, err := n.AppMigrator(script.App, n.InternalConnection).Status([]Migration{script.ToMigration()})
+	if err != nil {
+		return err
+	}
+	for _, m := range status {
+		if m.Version == script.Version && m.Applied && m.Drift {
+			return fmt.Errorf("version %s of %s is already applied with another script, upload a new version", script.Version, script.App)
+		}
+	}
+	res := n.InternalConnection.ExecOneSQLParameterized(orm.ParametereizedSQL{
+		Query:  fmt.Sprintf("INSERT OR REPLACE INTO %s (app, version, name, up_sql, down_sql) VALUES (?, ?, ?, ?, ?)", script.TableName()),
+		Values: []interface{}{script.App, script.Version, script.Name, script.UpSQL, script.DownSQL},
+	})
+	return res.Error
+}
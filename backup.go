@@ -0,0 +1,178 @@
+package suresql
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DBMS_BACKUP_ENDPOINT  = "/db/backup"
+	BACKUP_FORMAT_SQLITE  = "sqlite" // binary SQLite file
+	BACKUP_FORMAT_SQL     = "sql"    // SQL text dump
+	BACKUP_FILE_PREFIX    = "suresql-"
+	BACKUP_GZIP_EXTENSION = ".gz"
+	BACKUP_TIME_FORMAT    = "20060102-150405"
+	BACKUP_TIMEOUT        = 10 * time.Minute // backup of a big database takes longer than the normal http timeout
+)
+
+// Result of a backup that is written to the backup directory
+type BackupResult struct {
+	File     string    `json:"file"`
+	Format   string    `json:"format"`
+	Size     int64     `json:"size"`
+	Gzip     bool      `json:"gzip,omitempty"`
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration"` // in milliseconds
+}
+
+// Check the backup format, empty is the SQLite file
+func BackupFormat(format string) (string, error) {
+	switch format {
+	case "", BACKUP_FORMAT_SQLITE:
+		return BACKUP_FORMAT_SQLITE, nil
+	case BACKUP_FORMAT_SQL:
+		return BACKUP_FORMAT_SQL, nil
+	}
+	return "", fmt.Errorf("backup format must be %s or %s", BACKUP_FORMAT_SQLITE, BACKUP_FORMAT_SQL)
+}
+
+// File extension of the backup format
+func BackupExtension(format string) string {
+	if format == BACKUP_FORMAT_SQL {
+		return ".sql"
+	}
+	return ".sqlite"
+}
+
+// Consistent snapshot of the database from the DBMS (rqlite /db/backup). The caller must close the reader,
+// then call MarkBackup if the whole snapshot was received.
+func (n SureSQLNode) Backup(format string) (io.ReadCloser, error) {
+	endpoint := DBMS_BACKUP_ENDPOINT
+	if format == BACKUP_FORMAT_SQL {
+		endpoint += "?fmt=sql"
+	}
+	conf := n.InternalConfig
+	conf.HttpTimeout = BACKUP_TIMEOUT
+	resp, err := DBMSRequest(conf, http.MethodGet, endpoint, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Write the snapshot to the backup directory (gzip if the setting is on). The file is written to a
+// temporary name first so an incomplete backup is never left with the backup name.
+func (n *SureSQLNode) BackupToDir(format string) (BackupResult, error) {
+	start := time.Now()
+	result := BackupResult{Format: format, Time: start, Gzip: n.BackupGzip}
+	if n.BackupDir == "" {
+		return result, fmt.Errorf("backup directory is not set (setting %s.%s)", SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_DIR)
+	}
+	if err := os.MkdirAll(n.BackupDir, 0o750); err != nil {
+		return result, err
+	}
+
+	reader, err := n.Backup(format)
+	if err != nil {
+		return result, err
+	}
+	defer reader.Close()
+
+	result.File = filepath.Join(n.BackupDir, BACKUP_FILE_PREFIX+start.UTC().Format(BACKUP_TIME_FORMAT)+BackupExtension(format))
+	if result.Gzip {
+		result.File += BACKUP_GZIP_EXTENSION
+	}
+	tmpFile := result.File + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return result, err
+	}
+	err = writeBackup(f, reader, result.Gzip)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if info, serr := os.Stat(tmpFile); serr == nil {
+			result.Size = info.Size()
+		}
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return result, err
+	}
+	if err := os.Rename(tmpFile, result.File); err != nil {
+		os.Remove(tmpFile)
+		return result, err
+	}
+
+	// n can be the copy from Node(), the time goes to the node itself
+	CurrentNode.MarkBackup(start)
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return result, nil
+}
+
+func writeBackup(w io.Writer, reader io.Reader, gz bool) error {
+	if !gz {
+		_, err := io.Copy(w, reader)
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if _, err := io.Copy(zw, reader); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// Record the time of a successful backup, this is the last_backup in the status. Called on CurrentNode,
+// the status is read by the handlers and the scheduler so it is changed under the node lock.
+func (n *SureSQLNode) MarkBackup(t time.Time) {
+	updateNode(func(node *SureSQLNode) error {
+		node.markBackup(t)
+		return nil
+	})
+}
+
+// The caller holds the node lock
+func (n *SureSQLNode) markBackup(t time.Time) {
+	if t.After(n.Status.LastBackup) {
+		n.Status.LastBackup = t
+	}
+}
+
+// Backup files in the backup directory, newest first
+func (n SureSQLNode) BackupFiles() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(n.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), BACKUP_FILE_PREFIX) || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	// file name has the time, so sort by name is sort by time
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() > files[j].Name() })
+	return files, nil
+}
+
+// Set the last backup from the newest file in the backup directory, ie: after restart. It is called when
+// the setting is applied, the caller holds the node lock.
+func (n *SureSQLNode) RefreshLastBackup() {
+	files, err := n.BackupFiles()
+	if err != nil || len(files) == 0 {
+		return
+	}
+	n.markBackup(files[0].ModTime())
+}
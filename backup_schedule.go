@@ -0,0 +1,143 @@
+package suresql
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// Scheduled backup to the backup directory, the interval is read every round so the setting can be changed
+// at run-time. Interval 0 means disabled, checked again after BACKUP_SCHEDULE_IDLE.
+const (
+	BACKUP_SCHEDULE_IDLE = time.Minute
+)
+
+// Result of the scheduled backups, returned with the status so a failing backup is visible
+type BackupStatus struct {
+	Interval            string    `json:"interval"`
+	LastRun             time.Time `json:"last_run,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastFile            string    `json:"last_file,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Removed             int       `json:"removed,omitempty"` // files removed by retention in the last run
+}
+
+var (
+	backupStop   chan struct{}
+	backupLock   sync.Mutex
+	backupState  BackupStatus
+	backupStateM sync.RWMutex
+
+	// Called after each scheduled backup, the server sets this to write the result to the access logs
+	BackupHook func(result BackupResult, err error)
+)
+
+func (n *SureSQLNode) StartBackupScheduler() {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	if backupStop != nil {
+		return
+	}
+	backupStop = make(chan struct{})
+	stop := backupStop
+	go func() {
+		for {
+			node := Node()
+			interval := node.BackupInterval
+			if interval > 0 && node.BackupDir != "" {
+				// after restart the newest file may still be fresh enough, a failed run also waits the interval
+				if wait := time.Until(node.Status.LastBackup.Add(interval)); wait > 0 {
+					interval = wait
+				} else {
+					node.RunScheduledBackup()
+				}
+			} else {
+				interval = BACKUP_SCHEDULE_IDLE
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (n *SureSQLNode) StopBackupScheduler() {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	if backupStop != nil {
+		close(backupStop)
+		backupStop = nil
+	}
+}
+
+// Backup once to the backup directory then remove the old files (keep-last-N)
+func (n *SureSQLNode) RunScheduledBackup() {
+	result, err := n.BackupToDir(BACKUP_FORMAT_SQLITE)
+	removed := 0
+	if err == nil {
+		removed, err = n.PruneBackups()
+		if err != nil {
+			err = fmt.Errorf("backup saved but retention failed: %w", err)
+		}
+	}
+
+	backupStateM.Lock()
+	backupState.Interval = n.BackupInterval.String()
+	backupState.LastRun = result.Time
+	backupState.Removed = removed
+	if err != nil {
+		backupState.LastError = err.Error()
+		backupState.ConsecutiveFailures++
+	} else {
+		backupState.LastSuccess = result.Time
+		backupState.LastFile = result.File
+		backupState.LastError = ""
+		backupState.ConsecutiveFailures = 0
+	}
+	backupStateM.Unlock()
+
+	if err != nil {
+		simplelog.LogErrorStr("backup", err, "scheduled backup failed")
+	}
+	if BackupHook != nil {
+		BackupHook(result, err)
+	}
+}
+
+// Remove the oldest backup files, only keep the newest BackupKeep files
+func (n SureSQLNode) PruneBackups() (int, error) {
+	if n.BackupKeep <= 0 {
+		return 0, nil
+	}
+	files, err := n.BackupFiles()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for i := n.BackupKeep; i < len(files); i++ {
+		if err := os.Remove(filepath.Join(n.BackupDir, files[i].Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Status of the scheduled backups, nil if the schedule is off and never ran
+func (n SureSQLNode) BackupStatus() *BackupStatus {
+	backupStateM.RLock()
+	defer backupStateM.RUnlock()
+	if n.BackupInterval <= 0 && backupState.LastRun.IsZero() {
+		return nil
+	}
+	status := backupState
+	status.Interval = n.BackupInterval.String()
+	return &status
+}
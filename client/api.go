@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/medatechnology/suresql"
+
+	orm "github.com/medatechnology/simpleorm"
+)
+
+// ExecResult is orm.BasicSQLResult without the error, statement errors are returned as APIError
+type ExecResult struct {
+	Timing       float64 `json:"Timing"`
+	RowsAffected int     `json:"RowsAffected"`
+	LastInsertID int     `json:"LastInsertID"`
+}
+
+// ExecResponse is suresql.SQLResponse, the response of Exec and Insert
+type ExecResponse struct {
+	Results       []ExecResult `json:"results"`
+	ExecutionTime float64      `json:"execution_time"`
+	RowsAffected  int          `json:"rows_affected"`
+}
+
+// Connect with the username and password of the config, the token is kept in the client
+func (c *Client) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect that stops when ctx is done
+func (c *Client) ConnectContext(ctx context.Context) error {
+	var token suresql.TokenTable
+	err := c.CallContext(ctx, http.MethodPost, PATH_CONNECT, map[string]string{
+		"username": c.config.Username,
+		"password": c.config.Password,
+	}, &token, false)
+	if err != nil {
+		return err
+	}
+	c.setToken(token)
+	return nil
+}
+
+// Status of the node, peers health, cluster and backup
+func (c *Client) Status() (suresql.NodeStatusResponse, error) {
+	return c.StatusContext(context.Background())
+}
+
+// StatusContext is Status that stops when ctx is done
+func (c *Client) StatusContext(ctx context.Context) (suresql.NodeStatusResponse, error) {
+	var status suresql.NodeStatusResponse
+	err := c.CallContext(ctx, http.MethodGet, PATH_STATUS, nil, &status, true)
+	return status, err
+}
+
+// Exec runs raw SQL statements (INSERT, UPDATE, DELETE, CREATE ...)
+func (c *Client) Exec(statements ...string) (ExecResponse, error) {
+	return c.ExecRequest(suresql.SQLRequest{Statements: statements})
+}
+
+// ExecParams runs parameterized SQL statements, values are bound to the ? placeholders
+func (c *Client) ExecParams(sqls ...orm.ParametereizedSQL) (ExecResponse, error) {
+	return c.ExecRequest(suresql.SQLRequest{ParamSQL: sqls})
+}
+
+// ExecRequest is Exec with all the request options
+func (c *Client) ExecRequest(req suresql.SQLRequest) (ExecResponse, error) {
+	return c.ExecRequestContext(context.Background(), req)
+}
+
+// ExecRequestContext is ExecRequest that stops when ctx is done
+func (c *Client) ExecRequestContext(ctx context.Context, req suresql.SQLRequest) (ExecResponse, error) {
+	var resp ExecResponse
+	err := c.CallContext(ctx, http.MethodPost, PATH_SQL, req, &resp, true)
+	return resp, err
+}
+
+// Query a table with optional condition
+func (c *Client) Query(req suresql.QueryRequest) (suresql.QueryResponse, error) {
+	return c.QueryContext(context.Background(), req)
+}
+
+// QueryContext is Query that stops when ctx is done
+func (c *Client) QueryContext(ctx context.Context, req suresql.QueryRequest) (suresql.QueryResponse, error) {
+	var resp suresql.QueryResponse
+	err := c.CallContext(ctx, http.MethodPost, PATH_QUERY, req, &resp, true)
+	return resp, err
+}
+
+// QuerySQL runs raw SELECT statements, one response per statement
+func (c *Client) QuerySQL(statements ...string) (suresql.QueryResponseSQL, error) {
+	return c.QuerySQLRequest(suresql.SQLRequest{Statements: statements})
+}
+
+// QuerySQLParams runs parameterized SELECT statements, one response per statement
+func (c *Client) QuerySQLParams(sqls ...orm.ParametereizedSQL) (suresql.QueryResponseSQL, error) {
+	return c.QuerySQLRequest(suresql.SQLRequest{ParamSQL: sqls})
+}
+
+// QuerySQLRequest is QuerySQL with all the request options
+func (c *Client) QuerySQLRequest(req suresql.SQLRequest) (suresql.QueryResponseSQL, error) {
+	return c.QuerySQLRequestContext(context.Background(), req)
+}
+
+// QuerySQLRequestContext is QuerySQLRequest that stops when ctx is done
+func (c *Client) QuerySQLRequestContext(ctx context.Context, req suresql.SQLRequest) (suresql.QueryResponseSQL, error) {
+	var resp suresql.QueryResponseSQL
+	err := c.CallContext(ctx, http.MethodPost, PATH_QUERY_SQL, req, &resp, true)
+	return resp, err
+}
+
+// Insert records, the table is DBRecord.TableName
+func (c *Client) Insert(req suresql.InsertRequest) (ExecResponse, error) {
+	return c.InsertContext(context.Background(), req)
+}
+
+// InsertContext is Insert that stops when ctx is done
+func (c *Client) InsertContext(ctx context.Context, req suresql.InsertRequest) (ExecResponse, error) {
+	var resp ExecResponse
+	err := c.CallContext(ctx, http.MethodPost, PATH_INSERT, req, &resp, true)
+	return resp, err
+}
+
+// InsertRecords is Insert with the default options
+func (c *Client) InsertRecords(records ...orm.DBRecord) (ExecResponse, error) {
+	return c.Insert(suresql.InsertRequest{Records: records})
+}
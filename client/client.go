@@ -0,0 +1,324 @@
+// Package client is the Go SDK for the SureSQL /db API. It handles the API key headers, the token
+// (connect, refresh before it expires) and retries, and decodes the StandardResponse envelope.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/medatechnology/suresql"
+)
+
+const (
+	HEADER_API_KEY   = "API_KEY"
+	HEADER_CLIENT_ID = "CLIENT_ID"
+
+	DEFAULT_TIMEOUT        = 60 * time.Second
+	DEFAULT_RETRIES        = 3
+	DEFAULT_RETRY_WAIT     = 500 * time.Millisecond // doubled on every retry
+	DEFAULT_REFRESH_BEFORE = 30 * time.Second       // refresh the token this long before it expires
+
+	PATH_CONNECT   = "/db/connect"
+	PATH_REFRESH   = "/db/refresh"
+	PATH_STATUS    = "/db/api/status"
+	PATH_SQL       = "/db/api/sql"
+	PATH_QUERY     = "/db/api/query"
+	PATH_QUERY_SQL = "/db/api/querysql"
+	PATH_INSERT    = "/db/api/insert"
+)
+
+var (
+	ErrNotConnected = errors.New("not connected, call Connect first")
+)
+
+// Config of the client, only URL, APIKey and ClientID are required. Username and Password are kept
+// so the client can connect again when the refresh token is also expired.
+type Config struct {
+	URL           string        // SureSQL server, ie: http://localhost:5130
+	APIKey        string        // sent as API_KEY header
+	ClientID      string        // sent as CLIENT_ID header
+	Username      string        // for /db/connect
+	Password      string        // for /db/connect
+	Timeout       time.Duration // per request, default DEFAULT_TIMEOUT
+	MaxRetries    int           // retries on network error, 502, 503 and 504 (writes: refused and DBMS not connected only). Negative means no retry
+	RetryWait     time.Duration // wait before the first retry, doubled after that
+	RefreshBefore time.Duration // refresh the token this long before TokenExpiresAt
+	UseNumber     bool          // decode numbers in records as json.Number instead of float64
+	HTTPClient    *http.Client  // optional, to use own transport
+}
+
+// APIError is a response from the server that is not 2xx
+type APIError struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if len(e.Data) > 0 && string(e.Data) != "null" && string(e.Data) != "{}" {
+		return fmt.Sprintf("suresql: (%d) %s: %s", e.Status, e.Message, string(e.Data))
+	}
+	return fmt.Sprintf("suresql: (%d) %s", e.Status, e.Message)
+}
+
+// response is suresql.StandardResponse with the data kept raw, decoded per method
+type response struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Client is safe to be used by multiple goroutines
+type Client struct {
+	config Config
+	http   *http.Client
+	mu     sync.Mutex // guards token
+	token  suresql.TokenTable
+	// only one goroutine refreshes, refresh token can be used once
+	refreshMu sync.Mutex
+}
+
+// New client with the defaults for the empty config values, it is not connected yet
+func New(config Config) *Client {
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.Timeout == 0 {
+		config.Timeout = DEFAULT_TIMEOUT
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DEFAULT_RETRIES
+	}
+	if config.RetryWait == 0 {
+		config.RetryWait = DEFAULT_RETRY_WAIT
+	}
+	if config.RefreshBefore == 0 {
+		config.RefreshBefore = DEFAULT_REFRESH_BEFORE
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: config.Timeout}
+	}
+	return &Client{config: config, http: httpClient}
+}
+
+// Connect creates the client and connects with the username and password of the config
+func Connect(config Config) (*Client, error) {
+	return ConnectContext(context.Background(), config)
+}
+
+// ConnectContext is Connect that stops when ctx is done
+func ConnectContext(ctx context.Context, config Config) (*Client, error) {
+	c := New(config)
+	if err := c.ConnectContext(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Config returns the config with the defaults applied
+func (c *Client) Config() Config {
+	return c.config
+}
+
+// Token returns the current token, empty if not connected
+func (c *Client) Token() suresql.TokenTable {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Call sends the request to path and decodes the data of the response into out (can be nil).
+// With auth the token is refreshed before it expires, and once more if the server answers 401.
+func (c *Client) Call(method, path string, body, out interface{}, auth bool) error {
+	return c.CallContext(context.Background(), method, path, body, out, auth)
+}
+
+// CallContext is Call that stops when ctx is done, also while waiting to retry
+func (c *Client) CallContext(ctx context.Context, method, path string, body, out interface{}, auth bool) error {
+	if auth {
+		if err := c.ensureToken(ctx); err != nil {
+			return err
+		}
+	}
+	used := c.Token().Token
+	resp, err := c.doRetry(ctx, method, path, body, auth)
+	var apiErr *APIError
+	if auth && errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		// Token can be removed on the server (revoked, server restarted) before it expires
+		if err = c.renewToken(ctx, used); err == nil {
+			resp, err = c.doRetry(ctx, method, path, body, auth)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil || len(resp.Data) == 0 || string(resp.Data) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(resp.Data))
+	if c.config.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("suresql: cannot decode response of %s: %w", path, err)
+	}
+	return nil
+}
+
+// Refresh the token using the refresh token, if it fails connect again with the credential
+func (c *Client) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.refresh(context.Background())
+}
+
+func (c *Client) refresh(ctx context.Context) error {
+	current := c.Token()
+	if current.Refresh == "" {
+		return c.ConnectContext(ctx)
+	}
+	var token suresql.TokenTable
+	if err := c.CallContext(ctx, http.MethodPost, PATH_REFRESH, suresql.TokenTable{Refresh: current.Refresh}, &token, false); err != nil {
+		if c.config.Username == "" || ctx.Err() != nil {
+			return err
+		}
+		return c.ConnectContext(ctx)
+	}
+	c.setToken(token)
+	return nil
+}
+
+// Refresh if the token is still the one that was rejected, other goroutine might have refreshed it already
+func (c *Client) renewToken(ctx context.Context, rejected string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.Token().Token != rejected {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+func (c *Client) setToken(token suresql.TokenTable) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+func (c *Client) ensureToken(ctx context.Context) error {
+	if c.tokenValid() {
+		return nil
+	}
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	// Checked again, other goroutine might have refreshed it while waiting
+	if c.tokenValid() {
+		return nil
+	}
+	if c.Token().Token == "" {
+		if c.config.Username == "" {
+			return ErrNotConnected
+		}
+		return c.ConnectContext(ctx)
+	}
+	return c.refresh(ctx)
+}
+
+// Token exists and does not expire within RefreshBefore (zero expiry means it is not known)
+func (c *Client) tokenValid() bool {
+	token := c.Token()
+	if token.Token == "" {
+		return false
+	}
+	return token.TokenExpiresAt.IsZero() || time.Now().Add(c.config.RefreshBefore).Before(token.TokenExpiresAt)
+}
+
+// Retry only when the server is not reachable or not ready (gateway, 503 while DBMS is not connected).
+// Writes are retried only if they surely did not reach the handler, so they are never applied twice.
+func (c *Client) doRetry(ctx context.Context, method, path string, body interface{}, auth bool) (response, error) {
+	write := isWrite(path)
+	wait := c.config.RetryWait
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, method, path, body, auth)
+		if err == nil || attempt >= c.config.MaxRetries || ctx.Err() != nil || !retryable(err, write) {
+			return resp, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, ctx.Err()
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// Paths that change the data
+func isWrite(path string) bool {
+	return path == PATH_SQL || path == PATH_INSERT
+}
+
+func retryable(err error, write bool) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusServiceUnavailable:
+			return !write || apiErr.Message == suresql.MESSAGE_DBMS_NOT_CONNECTED
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return !write
+		}
+		return false
+	}
+	// Network error, a write could have been applied unless the connection was refused
+	return !write || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, auth bool) (response, error) {
+	var resp response
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return resp, fmt.Errorf("suresql: cannot encode request: %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.config.URL+path, reader)
+	if err != nil {
+		return resp, err
+	}
+	req.Header.Set(HEADER_API_KEY, c.config.APIKey)
+	req.Header.Set(HEADER_CLIENT_ID, c.config.ClientID)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+c.Token().Token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		// Proxies and some middleware do not answer with StandardResponse
+		resp = response{Status: res.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return resp, &APIError{Status: res.StatusCode, Message: resp.Message, Data: resp.Data}
+	}
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/medatechnology/suresql"
+)
+
+func TestRetryable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	tests := []struct {
+		name  string
+		err   error
+		write bool
+		want  bool
+	}{
+		{name: "read 503", err: &APIError{Status: http.StatusServiceUnavailable}, want: true},
+		{name: "write 503", err: &APIError{Status: http.StatusServiceUnavailable}, write: true, want: false},
+		{name: "write 503 DBMS not connected", err: &APIError{Status: http.StatusServiceUnavailable, Message: suresql.MESSAGE_DBMS_NOT_CONNECTED}, write: true, want: true},
+		{name: "read 502", err: &APIError{Status: http.StatusBadGateway}, want: true},
+		{name: "write 502", err: &APIError{Status: http.StatusBadGateway}, write: true, want: false},
+		{name: "read 504", err: &APIError{Status: http.StatusGatewayTimeout}, want: true},
+		{name: "read 500", err: &APIError{Status: http.StatusInternalServerError}, want: false},
+		{name: "read 401", err: &APIError{Status: http.StatusUnauthorized}, want: false},
+		{name: "wrapped API error", err: fmt.Errorf("call: %w", &APIError{Status: http.StatusBadGateway}), want: true},
+		{name: "read network error", err: reset, want: true},
+		{name: "write network error", err: reset, write: true, want: false},
+		{name: "write connection refused", err: refused, write: true, want: true},
+		{name: "read other error", err: errors.New("timeout"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err, tt.write); got != tt.want {
+				t.Errorf("retryable(%v, write=%t) = %t, want %t", tt.err, tt.write, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/goutil/object"
+	orm "github.com/medatechnology/simpleorm"
+)
+
+// DecodeRecords converts the records into T, the columns are matched with the json or db tag of T
+func DecodeRecords[T any](records []orm.DBRecord) []T {
+	list := make([]T, 0, len(records))
+	for _, r := range records {
+		list = append(list, object.MapToStructSlow[T](numbersToFloat(r.Data)))
+	}
+	return list
+}
+
+// QueryAs runs Query and decodes the records into T
+func QueryAs[T any](c *Client, req suresql.QueryRequest) ([]T, error) {
+	resp, err := c.Query(req)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRecords[T](resp.Records), nil
+}
+
+// QuerySQLAs runs one raw SELECT statement and decodes the records into T
+func QuerySQLAs[T any](c *Client, statement string) ([]T, error) {
+	resp, err := c.QuerySQL(statement)
+	if err != nil || len(resp) == 0 {
+		return []T{}, err
+	}
+	return DecodeRecords[T](resp[0].Records), nil
+}
+
+// QuerySQLParamsAs runs one parameterized SELECT statement and decodes the records into T
+func QuerySQLParamsAs[T any](c *Client, sql orm.ParametereizedSQL) ([]T, error) {
+	resp, err := c.QuerySQLParams(sql)
+	if err != nil || len(resp) == 0 {
+		return []T{}, err
+	}
+	return DecodeRecords[T](resp[0].Records), nil
+}
+
+// MapToStruct only knows float64 numbers, json.Number comes from Config.UseNumber
+func numbersToFloat(data map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if n, ok := v.(json.Number); ok {
+			v, _ = n.Float64()
+		}
+		converted[k] = v
+	}
+	return converted
+}
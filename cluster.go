@@ -0,0 +1,167 @@
+package suresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	DBMS_NODES_ENDPOINT = "/nodes?ver=2"
+	UNKNOWN_NODE_NUMBER = -1
+)
+
+// Node of the DBMS cluster from rqlite /nodes
+type DBMSNode struct {
+	ID         string `json:"id"`
+	APIAddr    string `json:"api_addr"`
+	Addr       string `json:"addr"`
+	Voter      bool   `json:"voter"`
+	Reachable  bool   `json:"reachable"`
+	Leader     bool   `json:"leader"`
+	NodeNumber int    `json:"node_number"` // matched node from nodes settings, -1 if not matched
+}
+
+// Difference between the real DBMS cluster and the nodes settings
+type ClusterDrift struct {
+	InSync      bool          `json:"in_sync"`
+	DBMSNodes   []DBMSNode    `json:"dbms_nodes"`
+	Missing     []NodeSetting `json:"missing,omitempty"`     // in settings, but not in the DBMS cluster
+	Extra       []DBMSNode    `json:"extra,omitempty"`       // in the DBMS cluster, but not in settings
+	Unreachable []DBMSNode    `json:"unreachable,omitempty"` // in the DBMS cluster, but not reachable by the leader
+	Leader      string        `json:"leader"`                // DBMS leader address from status
+	LeaderNode  int           `json:"leader_node"`           // node number of the DBMS leader, -1 if not matched
+	WrongLeader bool          `json:"wrong_leader"`          // DBMS leader is not the configured leader (LEADER_NODE_NUMBER)
+	Error       string        `json:"error,omitempty"`
+}
+
+// Short description for status message
+func (d ClusterDrift) String() string {
+	if d.Error != "" {
+		return "cluster discovery failed: " + d.Error
+	}
+	if d.InSync {
+		return "DBMS cluster matches nodes settings"
+	}
+	msg := fmt.Sprintf("DBMS cluster drift: %d missing, %d extra, %d unreachable", len(d.Missing), len(d.Extra), len(d.Unreachable))
+	if d.WrongLeader {
+		msg += fmt.Sprintf(", leader is node %d instead of %d", d.LeaderNode, LEADER_NODE_NUMBER)
+	}
+	return msg
+}
+
+// Get the DBMS cluster members from rqlite /nodes. Version 2 returns {"nodes": [...]}, older returns map by ID.
+func GetDBMSNodes(conf SureSQLDBMSConfig) ([]DBMSNode, error) {
+	resp, err := DBMSRequest(conf, http.MethodGet, DBMS_NODES_ENDPOINT, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode nodes response: %w", err)
+	}
+	var nodes []DBMSNode
+	if list, ok := raw["nodes"]; ok {
+		if err := json.Unmarshal(list, &nodes); err != nil {
+			return nil, fmt.Errorf("failed to decode nodes: %w", err)
+		}
+	} else {
+		for id, r := range raw {
+			var node DBMSNode
+			if err := json.Unmarshal(r, &node); err != nil {
+				continue
+			}
+			if node.ID == "" {
+				node.ID = id
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// Compare the DBMS cluster (/nodes and leader from /status) with the nodes settings. DBMS node is matched
+// by the host of api_addr or addr against the ip or hostname of the node (this node also by DBMS_HOST).
+func (n SureSQLNode) DiscoverCluster() ClusterDrift {
+	drift := ClusterDrift{LeaderNode: UNKNOWN_NODE_NUMBER}
+	nodes, err := GetDBMSNodes(n.InternalConfig)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift
+	}
+	// Leader from status, /nodes also has the flag but status is from the raft itself
+	drift.Leader, _ = n.InternalConnection.Leader()
+
+	settings := n.NodeSettings()
+	matched := make(map[int]bool)
+	for i := range nodes {
+		nodes[i].NodeNumber = UNKNOWN_NODE_NUMBER
+		for _, ns := range settings {
+			if matched[ns.NodeNumber] {
+				continue
+			}
+			if n.isSameHost(nodes[i], ns) {
+				nodes[i].NodeNumber = ns.NodeNumber
+				matched[ns.NodeNumber] = true
+				break
+			}
+		}
+		if nodes[i].NodeNumber == UNKNOWN_NODE_NUMBER {
+			drift.Extra = append(drift.Extra, nodes[i])
+		}
+		if !nodes[i].Reachable {
+			drift.Unreachable = append(drift.Unreachable, nodes[i])
+		}
+		if nodes[i].Leader || (drift.Leader != "" && nodes[i].Addr == drift.Leader) {
+			drift.LeaderNode = nodes[i].NodeNumber
+		}
+	}
+	for _, ns := range settings {
+		if !matched[ns.NodeNumber] {
+			drift.Missing = append(drift.Missing, ns)
+		}
+	}
+	drift.DBMSNodes = nodes
+	drift.WrongLeader = drift.LeaderNode != LEADER_NODE_NUMBER
+	drift.InSync = len(drift.Missing) == 0 && len(drift.Extra) == 0 && len(drift.Unreachable) == 0 && !drift.WrongLeader
+	return drift
+}
+
+func (n SureSQLNode) isSameHost(dbms DBMSNode, ns NodeSetting) bool {
+	hosts := []string{hostOnly(dbms.APIAddr), hostOnly(dbms.Addr)}
+	candidates := []string{ns.IP, ns.Hostname}
+	if ns.IsThisNode {
+		candidates = append(candidates, n.InternalConfig.Host)
+	}
+	for _, h := range hosts {
+		for _, c := range candidates {
+			if h != "" && c != "" && strings.EqualFold(h, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Host without protocol and port, ie: http://10.0.0.1:4001 -> 10.0.0.1
+func hostOnly(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if strings.Contains(addr, "://") {
+		if u, err := url.Parse(addr); err == nil {
+			return u.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,101 @@
+package suresql
+
+import (
+	"strconv"
+	"strings"
+
+	orm "github.com/medatechnology/simpleorm"
+)
+
+const (
+	// Table name returned by the DBMS driver when it cannot get the table from raw SQL
+	UNKNOWN_TABLE_NAME = "unknown"
+)
+
+// Column metadata for query responses, DBRecord.Data is a map so the type and order is lost.
+// Name, Type and Order are from the DBMS result in the order of the SELECT, Type is the declared type
+// (ie: INTEGER, TEXT, DATETIME) and empty for expressions. Nullable and PrimaryKey are only known for the
+// columns of the table after FROM, Nullable is not set for the rest.
+type ColumnInfo struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Nullable   *bool  `json:"nullable,omitempty"`
+	Order      int    `json:"order"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+}
+
+// Columns with the same name (ie: JOIN of tables that both have id) would be one key in DBRecord.Data, so
+// the next ones get the number of the repeat like SQLite does for subqueries: id, id:1, id:2
+func uniqueColumnNames(names []string) []string {
+	used := make(map[string]bool, len(names))
+	unique := make([]string, len(names))
+	for i, name := range names {
+		key := name
+		for n := 1; used[key]; n++ {
+			key = name + ":" + strconv.Itoa(n)
+		}
+		used[key] = true
+		unique[i] = key
+	}
+	return unique
+}
+
+// Columns of the result in the order of the SELECT
+func resultColumns(names, types []string) []ColumnInfo {
+	columns := make([]ColumnInfo, len(names))
+	for i, name := range names {
+		columns[i] = ColumnInfo{Name: name, Order: i}
+		if i < len(types) {
+			columns[i].Type = strings.ToUpper(types[i])
+		}
+	}
+	return columns
+}
+
+// Declared columns of the table, read in the same DBMS request as the statement
+func tableInfoSQL(table string) orm.ParametereizedSQL {
+	return orm.ParametereizedSQL{
+		Query:  `SELECT name, type, "notnull", pk FROM pragma_table_info(?)`,
+		Values: []interface{}{table},
+	}
+}
+
+// Set Nullable and PrimaryKey of the result columns that are the table columns. A column with the same name
+// but another declared type is an alias of something else, it is not changed.
+func describeColumns(columns []ColumnInfo, tableInfo []orm.DBRecord) {
+	for _, r := range tableInfo {
+		name := toString(r.Data["name"])
+		for i := range columns {
+			if columns[i].Name != name || !strings.EqualFold(columns[i].Type, toString(r.Data["type"])) {
+				continue
+			}
+			nullable := toInt(r.Data["notnull"]) == 0
+			columns[i].Nullable = &nullable
+			columns[i].PrimaryKey = toInt(r.Data["pk"]) > 0
+		}
+	}
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// Values from DBMS via JSON are float64
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case bool:
+		if n {
+			return 1
+		}
+	}
+	return 0
+}
@@ -2,6 +2,8 @@ package suresql
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	orm "github.com/medatechnology/simpleorm"
 
@@ -23,10 +25,40 @@ const (
 	SETTING_KEY_REFRESH_EXP = "refresh_exp" // value int: in minutes
 	SETTING_KEY_TOKEN_TTL   = "token_ttl"   // value int: in minutes, beat for checking expiration
 
+	// Per role and per user token expiry, setting_key is the role name or username
+	SETTING_CATEGORY_TOKEN_ROLE = "token_role" // value string: token_exp|refresh_exp in minutes, empty or 0 uses the token settings
+	SETTING_CATEGORY_TOKEN_USER = "token_user" // value string: token_exp|refresh_exp in minutes, wins over the role
+	SETTING_TOKEN_DELIMITER     = "|"
+
 	SETTING_CATEGORY_CONNECTION = "connection"
 	SETTING_KEY_MAX_POOL        = "max_pool" // value int: 0 overwrite pool_on, meaning no pooling, automatically pool_on=false
 	SETTING_KEY_ENABLE_POOL     = "pool_on"  // value string: true or false
 
+	SETTING_CATEGORY_LIMIT         = "limit"
+	SETTING_KEY_MAX_ROWS           = "max_rows"           // value int: max rows per query response, 0 means no limit
+	SETTING_KEY_MAX_RESPONSE_BYTES = "max_response_bytes" // value int: max size of records per query response in bytes, 0 means no limit
+
+	SETTING_CATEGORY_ROUTING     = "routing"
+	SETTING_KEY_ROUTING_STRATEGY = "strategy"      // value string: round_robin or least_latency, only used if is_split_write
+	SETTING_KEY_PEER_RETRY_AFTER = "peer_retry"    // value int: in seconds, peer that is down is not used for this long
+	SETTING_KEY_WRITE_FORWARD    = "write_forward" // value string: proxy, redirect or off. How follower handles writes
+
+	WRITE_FORWARD_PROXY    = "proxy"    // follower sends the write to the leader and returns the leader's response
+	WRITE_FORWARD_REDIRECT = "redirect" // follower returns 307 with the leader address
+	WRITE_FORWARD_OFF      = "off"      // follower executes the write itself (DBMS might forward it)
+
+	SETTING_CATEGORY_HEALTH     = "health"
+	SETTING_KEY_HEALTH_INTERVAL = "interval" // value int: in seconds, how often the peers are checked, 0 means disabled
+
+	SETTING_CATEGORY_BACKUP     = "backup"
+	SETTING_KEY_BACKUP_DIR      = "dir"      // value string: local directory to write the backup files, empty means backup is only streamed
+	SETTING_KEY_BACKUP_INTERVAL = "interval" // value int: in minutes, how often the scheduled backup runs, 0 means disabled
+	SETTING_KEY_BACKUP_KEEP     = "keep"     // value int: number of newest backup files to keep, 0 means keep all
+	SETTING_KEY_BACKUP_GZIP     = "gzip"     // value bool: compress the backup files with gzip
+
+	SETTING_CATEGORY_RELOAD     = "reload"
+	SETTING_KEY_RELOAD_INTERVAL = "interval" // value int: in seconds, how often _configs and _settings are read again, 0 means disabled
+
 	SETTING_CATEGORY_NODES = "nodes"
 	SETTING_KEY_NODE_NAME  = "node_name" // value string: node_number;hostname;ip;mode
 	SETTING_NODE_DELIMITER = "|"
@@ -45,6 +77,13 @@ const (
 	SETTING_KEY_IS_SPLIT_WRITE    = "is_split_write"    // value bool(int): split write
 	SETTING_KEY_ENCRYPTION_METHOD = "encryption_method" // value string: "aes", "rsa", "none"
 
+	SETTING_CATEGORY_ACCESS        = "access"
+	SETTING_KEY_RAW_SQL_DENY_ROLES = "raw_sql_deny_roles" // value string: comma separated role names that cannot use /sql and /querysql
+	SETTING_KEY_RAW_SQL_DENY_USERS = "raw_sql_deny_users" // value string: comma separated usernames that cannot use /sql and /querysql
+	SETTING_LIST_DELIMITER         = ","
+
+	SETTING_KEY_CONSISTENCY_DENY_ROLES = "consistency_deny_roles" // value string: comma separated role names that cannot request linearizable or strong consistency
+
 	SETTING_CATEGORY_EMPTY = "nocategory"
 )
 
@@ -267,7 +306,15 @@ func OverwriteConfigFromEnvironment() {
 	// CurrentNode.Configs.SSL = tmpBool
 }
 
-// LoadConfigFromDB loads settings from _settings table
+// Duration from the environment, the default if it is not set, invalid or not positive
+func envDuration(key string, defaultValue time.Duration) time.Duration {
+	if d := utils.GetEnvDuration(key, defaultValue); d > 0 {
+		return d
+	}
+	return defaultValue
+}
+
+// LoadConfigFromDB loads settings from _settings table, at run-time the caller holds the node lock (updateNode)
 func LoadConfigFromDB(db *SureSQLDB) error {
 	record, err := (*db).SelectOne(CurrentNode.Config.TableName())
 	if err != nil {
@@ -290,21 +337,40 @@ func LoadSettingsFromDB(db *SureSQLDB) error {
 		}
 		return fmt.Errorf("failed to load configs from DB: %s", err)
 	}
+	CurrentNode.Settings.addRecords(records)
+	// fmt.Println("DEBUG: reading configs table:", len(records), " rows")
+	// fmt.Println("DEBUG: current node configs :", len(CurrentNode.DBConfigs), " category")
+	return err
+}
+
+// Read the _settings table into a new Settings, used by reload so CurrentNode.Settings is swapped at once.
+// No rows is not an error, all settings are then the defaults.
+func ReadSettingsFromDB(db *SureSQLDB) (Settings, error) {
+	settings := make(Settings)
+	records, err := (*db).SelectMany(SettingTable{}.TableName())
+	if err != nil {
+		if err == orm.ErrSQLNoRows {
+			return settings, nil
+		}
+		return settings, fmt.Errorf("failed to read settings from DB: %w", err)
+	}
+	settings.addRecords(records)
+	return settings, nil
+}
+
+func (c Settings) addRecords(records []orm.DBRecord) {
 	for _, r := range records {
 		tmp := object.MapToStruct[SettingTable](r.Data)
 		if tmp.Category == "" {
 			tmp.Category = SETTING_CATEGORY_EMPTY
 		}
-		tmpConfigMap, ok := CurrentNode.Settings[tmp.Category]
+		tmpConfigMap, ok := c[tmp.Category]
 		if !ok {
 			tmpConfigMap = make(SettingsMap)
 		}
 		tmpConfigMap[tmp.SettingKey] = tmp
-		CurrentNode.Settings[tmp.Category] = tmpConfigMap
+		c[tmp.Category] = tmpConfigMap
 	}
-	// fmt.Println("DEBUG: reading configs table:", len(records), " rows")
-	// fmt.Println("DEBUG: current node configs :", len(CurrentNode.DBConfigs), " category")
-	return err
 }
 
 // By category and key
@@ -326,3 +392,100 @@ func (c SettingsMap) SettingExist(key string) (SettingTable, bool) {
 	}
 	return SettingTable{}, false
 }
+
+// Put the setting into the map based on its category and key, replacing the old one if any
+func (c Settings) Set(setting SettingTable) {
+	category := setting.Category
+	if category == "" {
+		category = SETTING_CATEGORY_EMPTY
+	}
+	tmp, ok := c[category]
+	if !ok {
+		tmp = make(SettingsMap)
+	}
+	tmp[setting.SettingKey] = setting
+	c[category] = tmp
+}
+
+// Remove the setting from the map, do nothing if not exist
+func (c Settings) Remove(category, key string) {
+	if category == "" {
+		category = SETTING_CATEGORY_EMPTY
+	}
+	if tmp, ok := c[category]; ok {
+		delete(tmp, key)
+	}
+}
+
+// Copy of the settings, changed and then swapped so the handlers never read a map that is being written
+func (c Settings) Clone() Settings {
+	clone := make(Settings, len(c))
+	for cat, m := range c {
+		tmp := make(SettingsMap, len(m))
+		for key, s := range m {
+			tmp[key] = s
+		}
+		clone[cat] = tmp
+	}
+	return clone
+}
+
+// Returns the text value of the setting as list, separated by SETTING_LIST_DELIMITER
+func (c Settings) SettingList(category, key string) []string {
+	var list []string
+	tmp, ok := c.SettingExist(category, key)
+	if !ok {
+		return list
+	}
+	for _, v := range strings.Split(tmp.TextValue, SETTING_LIST_DELIMITER) {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Save the setting to _settings table (replacing the same category+key) then put it in CurrentNode.Settings
+func SaveSettingToDB(db *SureSQLDB, setting SettingTable) error {
+	sqls := []orm.ParametereizedSQL{
+		{
+			Query:  "DELETE FROM " + setting.TableName() + " WHERE category=? AND setting_key=?",
+			Values: []interface{}{setting.Category, setting.SettingKey},
+		},
+		{
+			Query: "INSERT INTO " + setting.TableName() + " (category, data_type, setting_key, text_value, float_value, int_value) VALUES (?, ?, ?, ?, ?, ?)",
+			Values: []interface{}{setting.Category, setting.DataType, setting.SettingKey,
+				setting.TextValue, setting.FloatValue, setting.IntValue},
+		},
+	}
+	_, err := (*db).ExecManySQLParameterized(sqls)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s.%s: %w", setting.Category, setting.SettingKey, err)
+	}
+	// Under the node lock, so two changes at the same time do not lose one
+	return updateNode(func(n *SureSQLNode) error {
+		settings := n.Settings.Clone()
+		settings.Set(setting)
+		n.Settings = settings
+		return nil
+	})
+}
+
+// Delete the setting from _settings table then remove it from CurrentNode.Settings
+func DeleteSettingFromDB(db *SureSQLDB, category, key string) error {
+	res := (*db).ExecOneSQLParameterized(orm.ParametereizedSQL{
+		Query:  "DELETE FROM " + SettingTable{}.TableName() + " WHERE category=? AND setting_key=?",
+		Values: []interface{}{category, key},
+	})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete setting %s.%s: %w", category, key, res.Error)
+	}
+	// Under the node lock, so two changes at the same time do not lose one
+	return updateNode(func(n *SureSQLNode) error {
+		settings := n.Settings.Clone()
+		settings.Remove(category, key)
+		n.Settings = settings
+		return nil
+	})
+}
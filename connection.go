@@ -0,0 +1,138 @@
+package suresql
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// When the internal DBMS is not connected (at start or lost later) the server keeps running in degraded
+// mode, the APIs answer "DBMS not connected" until the keeper connects again. The retry wait starts at
+// DBMS_RETRY_MIN and is doubled up to DBMS_RETRY_MAX, while connected the DBMS is checked every
+// DBMS_CHECK_INTERVAL.
+const (
+	DBMS_RETRY_MIN      = 2 * time.Second
+	DBMS_RETRY_MAX      = 30 * time.Second
+	DBMS_CHECK_INTERVAL = 10 * time.Second
+
+	// Message of the 503, the request was not handled so clients can send it again (also writes)
+	MESSAGE_DBMS_NOT_CONNECTED = "DBMS not connected"
+)
+
+var (
+	dbmsConnected atomic.Bool
+	// DBMS of this node is the cluster leader, from the last status check
+	dbmsLeader atomic.Bool
+	// ConnectInternal is done once, after that only the connection is checked
+	dbmsLoaded bool
+
+	connectStop     chan struct{}
+	connectStopLock sync.Mutex
+)
+
+func IsDBMSConnected() bool {
+	return dbmsConnected.Load()
+}
+
+func IsDBMSLeader() bool {
+	return dbmsLeader.Load()
+}
+
+// Status of the internal DBMS, keeps whether it is the leader (it can change while running)
+func (n *SureSQLNode) checkDBMS() error {
+	status, err := n.InternalConnection.Status()
+	if err != nil {
+		return err
+	}
+	dbmsLeader.Store(status.IsLeader)
+	return nil
+}
+
+// Node from the environment only, so the server can be created before the DBMS is connected. The rest
+// is set when ConnectInternal succeeds.
+func PrepareDisconnected() {
+	updateNode(func(n *SureSQLNode) error {
+		n.InternalConfig = LoadDBMSConfigFromEnvironment()
+		OverwriteConfigFromEnvironment()
+		if n.Settings == nil {
+			n.Settings = make(Settings)
+		}
+		n.ApplyAllConfig()
+		n.GetStatusFromSettings(n.InternalConfig)
+		return nil
+	})
+}
+
+// Keeps the internal connection, connects with backoff when it is not connected and checks it when it is
+func (n *SureSQLNode) StartConnectionKeeper() {
+	connectStopLock.Lock()
+	defer connectStopLock.Unlock()
+	if connectStop != nil {
+		return
+	}
+	// main already tried, only the first one before the keeper started
+	dbmsLoaded = IsDBMSConnected()
+	connectStop = make(chan struct{})
+	stop := connectStop
+	go func() {
+		wait := DBMS_RETRY_MIN
+		for {
+			interval := DBMS_CHECK_INTERVAL
+			if !IsDBMSConnected() {
+				if err := n.reconnect(); err != nil {
+					simplelog.LogErrorStr("dbms", err, "DBMS not connected, retry in "+wait.String())
+					interval = wait
+					wait = min(wait*2, DBMS_RETRY_MAX)
+				} else {
+					simplelog.LogThis("dbms", "DBMS connected")
+					wait = DBMS_RETRY_MIN
+				}
+			} else if err := n.checkDBMS(); err != nil {
+				dbmsConnected.Store(false)
+				simplelog.LogErrorStr("dbms", err, "DBMS connection lost")
+				interval = wait
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (n *SureSQLNode) StopConnectionKeeper() {
+	connectStopLock.Lock()
+	defer connectStopLock.Unlock()
+	if connectStop != nil {
+		close(connectStop)
+		connectStop = nil
+	}
+}
+
+// Never loaded: the whole ConnectInternal. Lost: the DB could be restored while it was gone, so the
+// config and settings are read again.
+func (n *SureSQLNode) reconnect() error {
+	if !dbmsLoaded {
+		before := Node().Config
+		if err := ConnectInternal(); err != nil {
+			return err
+		}
+		dbmsLoaded = true
+		// Token maps are made with the environment config, resize them with the one from DB
+		if ReloadHook != nil {
+			ReloadHook(before)
+		}
+		return nil
+	}
+	if err := n.checkDBMS(); err != nil {
+		return err
+	}
+	if _, err := Reload(false); err != nil {
+		return err
+	}
+	dbmsConnected.Store(true)
+	return nil
+}
@@ -0,0 +1,60 @@
+package suresql
+
+import (
+	"fmt"
+
+	"github.com/medatechnology/simpleorm/rqlite"
+)
+
+// Read consistency levels of rqlite, see https://rqlite.io/docs/api/read-consistency/
+const (
+	CONSISTENCY_NONE         = "none"
+	CONSISTENCY_WEAK         = "weak"
+	CONSISTENCY_LINEARIZABLE = "linearizable"
+	CONSISTENCY_STRONG       = "strong"
+)
+
+// Check the consistency level from the request, empty means use the DBMS_CONSISTENCY of the node
+func ValidateConsistency(level string) error {
+	switch level {
+	case "", CONSISTENCY_NONE, CONSISTENCY_WEAK, CONSISTENCY_LINEARIZABLE, CONSISTENCY_STRONG:
+		return nil
+	}
+	return fmt.Errorf("consistency must be %s, %s, %s or %s", CONSISTENCY_NONE, CONSISTENCY_WEAK, CONSISTENCY_LINEARIZABLE, CONSISTENCY_STRONG)
+}
+
+// Linearizable and strong reads go through the leader (strong even through raft), so these can be denied
+// per role in the access settings. None and weak are always allowed.
+func (n SureSQLNode) IsConsistencyAllowed(role, level string) bool {
+	if level != CONSISTENCY_LINEARIZABLE && level != CONSISTENCY_STRONG {
+		return true
+	}
+	if role == "" {
+		return true
+	}
+	for _, r := range n.Settings.SettingList(SETTING_CATEGORY_ACCESS, SETTING_KEY_CONSISTENCY_DENY_ROLES) {
+		if r == role {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a copy of the connection that uses the consistency level, the original connection (that is
+// shared by the user's requests) is not changed. Empty level returns the connection as is.
+func WithConsistency(db SureSQLDB, level string) SureSQLDB {
+	if level == "" {
+		return db
+	}
+	switch d := db.(type) {
+	case *rqlite.RQLiteDirectDB:
+		c := *d
+		c.Config.Consistency = level
+		return &c
+	case RoutedDB:
+		d.consistency = level
+		d.fallback = WithConsistency(d.fallback, level)
+		return d
+	}
+	return db
+}
@@ -8,9 +8,7 @@ import (
 	orm "github.com/medatechnology/simpleorm"
 
 	utils "github.com/medatechnology/goutil"
-	"github.com/medatechnology/goutil/medattlmap"
 	"github.com/medatechnology/goutil/metrics"
-	"github.com/medatechnology/goutil/object"
 	"github.com/medatechnology/goutil/print"
 	"github.com/medatechnology/goutil/simplelog"
 )
@@ -28,19 +26,23 @@ func PingPong() string {
 }
 
 // Check if pool is enabled, and max pool has not reached
-func (n SureSQLNode) IsPoolAvailable() bool {
-	if n.IsPoolEnabled && n.DBConnections.Len() < n.MaxPool {
+func (n *SureSQLNode) IsPoolAvailable() bool {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
+	if node := Node(); node.IsPoolEnabled && n.DBConnections.Len() < node.MaxPool {
 		return true
 	}
 	return false
 }
 
 // Get the DB connection from pool based on token
-func (n SureSQLNode) GetDBConnectionByToken(token string) (SureSQLDB, error) {
+func (n *SureSQLNode) GetDBConnectionByToken(token string) (SureSQLDB, error) {
 	var db SureSQLDB
-	if CurrentNode.IsPoolEnabled {
+	if Node().IsPoolEnabled {
 		// Get DBConnection based on token
+		poolLock.RLock()
 		dbInterface, ok := CurrentNode.DBConnections.Get(token)
+		poolLock.RUnlock()
 		if !ok {
 			return db, ErrNoDBConnection
 		}
@@ -51,26 +53,62 @@ func (n SureSQLNode) GetDBConnectionByToken(token string) (SureSQLDB, error) {
 	return db, nil
 }
 
+// Put the DB connection to the pool, it lives as long as the session (refresh token). ttl 0 is the map TTL
+func (n *SureSQLNode) AddDBConnection(token string, db SureSQLDB, ttl time.Duration) {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
+	n.DBConnections.Put(token, ttl, db)
+}
+
+func (n *SureSQLNode) RemoveDBConnection(token string) {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
+	n.DBConnections.Delete(token)
+}
+
 // rename the key for DB connection pool to use new token, this is usually because refresh token.
 // TODO: please don't use this anymore, when token is refreshed, the DB connection should be deleted
 // -     and re-create it again fresh with new expiration same with the token expiration.
-func (n *SureSQLNode) RenameDBConnection(old, new string) {
+func (n *SureSQLNode) RenameDBConnection(old, new string, ttl time.Duration) {
+	poolLock.RLock()
+	defer poolLock.RUnlock()
 	if val, ok := n.DBConnections.Get(old); ok {
-		n.DBConnections.Put(new, 0, val)
+		n.DBConnections.Put(new, ttl, val)
 		n.DBConnections.Delete(old)
 	}
 }
 
-// This should be run the first time this package got imported, which is
-// connecting to the DB locally / internally. Not yet used by the client.
-func ConnectInternal() error {
-	// Set the global variable for when server is started from making the DBMS connection
-	ServerStartTime = time.Now()
+// Close and remove all pooled connections (on shutdown), returns how many were closed
+func (n *SureSQLNode) CloseDBConnections() int {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	if n.DBConnections == nil {
+		return 0
+	}
+	closed := 0
+	for token := range n.DBConnections.Map() {
+		if val, ok := n.DBConnections.Get(token); ok {
+			if db, ok := val.(SureSQLDB); ok {
+				CloseDatabase(db)
+				closed++
+			}
+		}
+		n.DBConnections.Delete(token)
+	}
+	return closed
+}
 
-	// IMPROVE: Change this maybe reading from environment or settings table!
-	// CurrentNode.IsPoolEnabled = DEFAULT_POOL_ENABLED
-	// CurrentNode.MaxPool = DEFAULT_MAX_POOL
+// Stop the background loops, the server is already shut down
+func (n *SureSQLNode) StopBackground() {
+	n.StopConnectionKeeper()
+	n.StopHealthProber()
+	n.StopBackupScheduler()
+	n.StopReloadPoller()
+}
 
+// Load the environment and make the internal connection only, without reading config and settings.
+// Used by ConnectInternal and the CLI commands (ie: migrate) that must not start the node.
+func ConnectDBMS() error {
 	el := metrics.StartTimeIt("Loading environment...", 0)
 	utils.ReloadEnvEach(".env.dev", SURESQL_ENV_FILE)
 	metrics.StopTimeItPrint(el, "Done")
@@ -89,11 +127,45 @@ func ConnectInternal() error {
 	// Internal connection is used by the SureSQL Backend only
 	CurrentNode.InternalConnection = db
 	CurrentNode.InternalConfig = conf
+	// TODO: make this read from environment. Not in NewDatabase, that also makes the user and peer connections
+	CurrentNode.Status.DBMSDriver = "direct-rqlite"
 	// Preparing the DBPool connection that is called by the Handler /connect
 	metrics.StopTimeItPrint(el, "Done")
+	return nil
+}
+
+// This should be run the first time this package got imported, which is
+// connecting to the DB locally / internally. Not yet used by the client.
+// The keeper can run it while the server is running, so it is done under the node lock.
+func ConnectInternal() error {
+	return updateNode(func(*SureSQLNode) error {
+		return connectInternal()
+	})
+}
+
+func connectInternal() error {
+	// Set the global variable for when server is started from making the DBMS connection, not again on retry
+	if ServerStartTime.IsZero() {
+		ServerStartTime = time.Now()
+	}
+
+	// IMPROVE: Change this maybe reading from environment or settings table!
+	// CurrentNode.IsPoolEnabled = DEFAULT_POOL_ENABLED
+	// CurrentNode.MaxPool = DEFAULT_MAX_POOL
+
+	err := ConnectDBMS()
+	if err != nil {
+		return err
+	}
+	conf := CurrentNode.InternalConfig
+
+	// NewDatabase does not connect, without this an unreachable DBMS looks like a DB that is not initialized
+	if err := CurrentNode.checkDBMS(); err != nil {
+		return fmt.Errorf("DBMS not reachable: %w", err)
+	}
 
 	db_is_initialized := true
-	el = metrics.StartTimeIt("Reading config table...", 0)
+	el := metrics.StartTimeIt("Reading config table...", 0)
 	err = LoadConfigFromDB(&CurrentNode.InternalConnection)
 	if err != nil {
 		simplelog.LogErrorStr("init", err, "cannot load settings from DB, it is not yet initialized")
@@ -120,6 +192,17 @@ func ConnectInternal() error {
 		metrics.StopTimeItPrint(el, "Done")
 	}
 
+	// New migration files since the last start, settings are read after this so they are included
+	if db_is_initialized {
+		el = metrics.StartTimeIt("Applying new migrations...", 0)
+		if err := MigrateInternal(); err != nil {
+			// Not fatal, the node can still run with the current schema
+			metrics.StopTimeItPrint(el, err.Error())
+		} else {
+			metrics.StopTimeItPrint(el, "Done")
+		}
+	}
+
 	// Make the configMaps before reading from DB
 	CurrentNode.Settings = make(Settings)
 
@@ -132,29 +215,40 @@ func ConnectInternal() error {
 	metrics.StopTimeItPrint(el, "Done")
 
 	el = metrics.StartTimeIt("Reading DBMS status...", 0)
-	_, err = GetStatusInternal(CurrentNode.InternalConnection, NODE_MODE)
+	status, err := CurrentNode.InternalConnection.Status()
 	if err != nil {
 		simplelog.LogErrorStr("init", err, "cannot get status from DB")
 		return err
 	}
+	CurrentNode.applyDBMSStatus(&status)
 	metrics.StopTimeItPrint(el, "Done")
 
 	// Setup the DB Connection TTLMap, use RefreshTokenExp (longer) so when refreshed, the DBConnection is still there.
 	el = metrics.StartTimeIt("Applying config table and settings to Node status...", 0)
 	CurrentNode.ApplyAllConfig()
-	CurrentNode.DBConnections = medattlmap.NewTTLMap(CurrentNode.Config.RefreshExp, CurrentNode.Config.TTLTicker)
+	// Under the pool lock, the server can be running already when it is connected on retry
+	CurrentNode.ResizeDBConnections(CurrentNode.Config.RefreshExp, CurrentNode.Config.TTLTicker, nil)
 	CurrentNode.GetStatusFromSettings(conf)
 	metrics.StopTimeItPrint(el, "Done")
 
-	// QUESTION: Just to be safe, put the pool that we get from this node * number of peers
-	// This is the readpool only, for write pool we do not count, because usually it's only 1
+	CurrentNode.ApplyPeersPool()
+	dbmsConnected.Store(true)
+	return nil
+}
+
+// QUESTION: Just to be safe, put the pool that we get from this node * number of peers
+// This is the readpool only, for write pool we do not count, because usually it's only 1
+func (n *SureSQLNode) ApplyPeersPool() {
 	// fmt.Println("Status == ", CurrentNode.Status)
 	// fmt.Println("Status.MaxPool == ", CurrentNode.Status.MaxPool)
 	// fmt.Println("Status.Peers == ", len(CurrentNode.Status.Peers))
-	if len(CurrentNode.Status.Peers) > 0 {
-		CurrentNode.MaxPool = CurrentNode.Status.MaxPool * len(CurrentNode.Status.Peers)
+	// MaxPool is the setting (per node) here, called after ApplyAllConfig
+	if n.MaxPool > 0 {
+		n.Status.MaxPool = n.MaxPool
+	}
+	if len(n.Status.Peers) > 0 {
+		n.MaxPool = n.Status.MaxPool * len(n.Status.Peers)
 	}
-	return nil
 }
 
 // This is the status for SureSQL Nodes (not the internal DBMS nodes)
@@ -190,8 +284,37 @@ func (n *SureSQLNode) GetStatusFromSettings(conf SureSQLDBMSConfig) {
 	// DBSize
 }
 
+// URL of the SureSQL node that executes the writes: the peer whose DBMS is the leader, from the last health
+// check of the peers (the SureSQL node and its DBMS must be up). Returns false if the DBMS of this node is the
+// leader or the leader is not known, then the write runs here and the DBMS sends it to its leader. Peers URL
+// is hostname:port from nodes settings, the protocol follows this node.
+func (n SureSQLNode) LeaderURL() (string, bool) {
+	if IsDBMSLeader() || n.Router == nil {
+		return "", false
+	}
+	for _, p := range n.Router.Peers() {
+		if p.DBMS != nil && p.DBMS.IsLeader && p.IsUp && p.IsNodeUp {
+			return n.PeerURL(p.URL), true
+		}
+	}
+	return "", false
+}
+
+// Complete URL of the peer SureSQL node, if the hostname has no protocol then follow this node.
+func (n SureSQLNode) PeerURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if strings.Contains(host, "://") {
+		return host
+	}
+	prot := "http://"
+	if n.Config.SSL {
+		prot = "https://"
+	}
+	return prot + host
+}
+
 // Apply config if they are changed from DB, only few that can be changed and effected at run-time
-// NOTE: this is hard-coded
+// NOTE: this is hard-coded. At run-time it is called in updateNode, the readers use the published copy
 func (n *SureSQLNode) ApplySettings(category, key string) bool {
 	res := false
 	tmp, ok := n.Settings.SettingExist(category, key)
@@ -199,23 +322,24 @@ func (n *SureSQLNode) ApplySettings(category, key string) bool {
 	switch category {
 	case SETTING_CATEGORY_TOKEN:
 		switch key {
+		// Without the setting, the environment (SURESQL_TOKEN_EXP etc) then the default
 		case SETTING_KEY_TOKEN_EXP:
-			if !ok {
-				n.Config.TokenExp = DEFAULT_TOKEN_EXPIRES_MINUTES
+			if !ok || tmp.IntValue <= 0 {
+				n.Config.TokenExp = envDuration("SURESQL_TOKEN_EXP", DEFAULT_TOKEN_EXPIRES_MINUTES)
 			} else {
 				n.Config.TokenExp = time.Duration(tmp.IntValue) * time.Minute
 			}
 			res = true
 		case SETTING_KEY_REFRESH_EXP:
-			if !ok {
-				n.Config.RefreshExp = DEFAULT_REFRESH_EXPIRES_MINUTES
+			if !ok || tmp.IntValue <= 0 {
+				n.Config.RefreshExp = envDuration("SURESQL_REFRESH_EXP", DEFAULT_REFRESH_EXPIRES_MINUTES)
 			} else {
 				n.Config.RefreshExp = time.Duration(tmp.IntValue) * time.Minute
 			}
 			res = true
 		case SETTING_KEY_TOKEN_TTL:
-			if !ok {
-				n.Config.TTLTicker = DEFAULT_TTL_TICKER_MINUTES
+			if !ok || tmp.IntValue <= 0 {
+				n.Config.TTLTicker = envDuration("SURESQL_TOKEN_TTL", DEFAULT_TTL_TICKER_MINUTES)
 			} else {
 				n.Config.TTLTicker = time.Duration(tmp.IntValue) * time.Minute
 			}
@@ -243,28 +367,144 @@ func (n *SureSQLNode) ApplySettings(category, key string) bool {
 			}
 		default:
 		}
+	case SETTING_CATEGORY_LIMIT:
+		switch key {
+		case SETTING_KEY_MAX_ROWS:
+			if ok {
+				n.MaxRows = tmp.IntValue
+				res = true
+			} else {
+				n.MaxRows = DEFAULT_MAX_ROWS
+			}
+		case SETTING_KEY_MAX_RESPONSE_BYTES:
+			if ok {
+				n.MaxResponseBytes = tmp.IntValue
+				res = true
+			} else {
+				n.MaxResponseBytes = DEFAULT_MAX_RESPONSE_BYTES
+			}
+		default:
+		}
+	case SETTING_CATEGORY_ROUTING:
+		if n.Router == nil {
+			n.Router = NewPeerRouter()
+		}
+		switch key {
+		case SETTING_KEY_ROUTING_STRATEGY:
+			if ok && (tmp.TextValue == ROUTING_ROUND_ROBIN || tmp.TextValue == ROUTING_LEAST_LATENCY) {
+				n.Router.Configure(tmp.TextValue, 0)
+				res = true
+			} else {
+				n.Router.Configure(DEFAULT_ROUTING_STRATEGY, 0)
+			}
+		case SETTING_KEY_PEER_RETRY_AFTER:
+			if ok && tmp.IntValue > 0 {
+				n.Router.Configure("", time.Duration(tmp.IntValue)*time.Second)
+				res = true
+			} else {
+				n.Router.Configure("", DEFAULT_PEER_RETRY_AFTER)
+			}
+		case SETTING_KEY_WRITE_FORWARD:
+			switch tmp.TextValue {
+			case WRITE_FORWARD_PROXY, WRITE_FORWARD_REDIRECT, WRITE_FORWARD_OFF:
+				n.WriteForward = tmp.TextValue
+				res = ok
+			default:
+				n.WriteForward = DEFAULT_WRITE_FORWARD
+			}
+		default:
+		}
+	case SETTING_CATEGORY_HEALTH:
+		switch key {
+		case SETTING_KEY_HEALTH_INTERVAL:
+			if ok {
+				n.HealthInterval = time.Duration(tmp.IntValue) * time.Second
+				res = true
+			} else {
+				n.HealthInterval = DEFAULT_HEALTH_INTERVAL
+			}
+		default:
+		}
+	case SETTING_CATEGORY_BACKUP:
+		switch key {
+		case SETTING_KEY_BACKUP_DIR:
+			n.BackupDir = ""
+			if ok && tmp.TextValue != "" {
+				n.BackupDir = tmp.TextValue
+				// backups from before the restart are still there
+				n.RefreshLastBackup()
+				res = true
+			}
+		case SETTING_KEY_BACKUP_INTERVAL:
+			n.BackupInterval = 0
+			if ok && tmp.IntValue > 0 {
+				n.BackupInterval = time.Duration(tmp.IntValue) * time.Minute
+				res = true
+			}
+		case SETTING_KEY_BACKUP_KEEP:
+			n.BackupKeep = 0
+			if ok && tmp.IntValue > 0 {
+				n.BackupKeep = tmp.IntValue
+				res = true
+			}
+		case SETTING_KEY_BACKUP_GZIP:
+			n.BackupGzip = false
+			if ok {
+				n.BackupGzip, _ = tmp.GetValue().(bool)
+				res = true
+			}
+		default:
+		}
+	case SETTING_CATEGORY_RELOAD:
+		switch key {
+		case SETTING_KEY_RELOAD_INTERVAL:
+			n.ReloadInterval = 0
+			if ok && tmp.IntValue > 0 {
+				n.ReloadInterval = time.Duration(tmp.IntValue) * time.Second
+				res = true
+			}
+		default:
+		}
 	case SETTING_CATEGORY_NODES:
-		nodes := len(n.Settings[SETTING_CATEGORY_NODES])
-		for _, c := range n.Settings[SETTING_CATEGORY_NODES] {
-			// value string: node_number;hostname;ip;mode
-			// -- node_number|hostname|ip|mode   and the CONFIG_NODE_DELIMITER in this case is "|"
-
-			parsed := strings.Split(c.TextValue, SETTING_NODE_DELIMITER)
+		// Rebuild the peers, so removed nodes are gone as well
+		nodes := n.NodeSettings()
+		// Filled then swapped, the handlers and LeaderURL read the current map while this runs
+		peers := make(map[int]orm.StatusStruct)
+		var routes []PeerHealth
+		for _, ns := range nodes {
+			// value string: node_number|hostname|ip|mode   and the CONFIG_NODE_DELIMITER in this case is "|"
 			stat := orm.StatusStruct{
 				// overwrite the actual DBMS node_ID to use SureSQL NodeNumber as the string-type ID
-				NodeID:     parsed[0],
-				NodeNumber: object.Int(parsed[0], false), // this current node number
-				URL:        parsed[1],
-				Nodes:      nodes, // number of nodes
-				Mode:       parsed[3],
+				NodeID:     fmt.Sprintf("%d", ns.NodeNumber),
+				NodeNumber: ns.NodeNumber, // this current node number
+				URL:        ns.Address(n.Config.Port),
+				Nodes:      len(nodes), // number of nodes
+				Mode:       ns.Mode,
 				MaxPool:    n.Status.MaxPool,
 			}
 			// Because the config contains the whole cluster information, including the master/this current node
 			// If not the same NodeNumber then it's the peers.
-			if n.Config.NodeNumber != stat.NodeNumber {
-				n.Status.Peers[stat.NodeNumber] = stat
+			if !ns.IsThisNode {
+				peers[stat.NodeNumber] = stat
+				// DBMS of the peer is reached by IP if there is, otherwise the hostname
+				dbmsHost := ns.IP
+				if dbmsHost == "" {
+					dbmsHost = ns.Hostname
+				}
+				routes = append(routes, PeerHealth{
+					NodeNumber: stat.NodeNumber,
+					URL:        stat.URL,
+					DBMSHost:   dbmsHost,
+					Mode:       stat.Mode,
+				})
 			}
 		}
+		n.Status.Peers = peers
+		res = true
+		if n.Router == nil {
+			n.Router = NewPeerRouter()
+		}
+		n.Router.SetPeers(routes)
 	case SETTING_CATEGORY_EMPTY:
 	default:
 	}
@@ -282,6 +522,17 @@ func (n *SureSQLNode) ApplyAllConfig() bool {
 	res = n.ApplySettings(SETTING_CATEGORY_TOKEN, SETTING_KEY_TOKEN_EXP) || res
 	res = n.ApplySettings(SETTING_CATEGORY_TOKEN, SETTING_KEY_REFRESH_EXP) || res
 	res = n.ApplySettings(SETTING_CATEGORY_TOKEN, SETTING_KEY_TOKEN_TTL) || res
+	res = n.ApplySettings(SETTING_CATEGORY_LIMIT, SETTING_KEY_MAX_ROWS) || res
+	res = n.ApplySettings(SETTING_CATEGORY_LIMIT, SETTING_KEY_MAX_RESPONSE_BYTES) || res
+	res = n.ApplySettings(SETTING_CATEGORY_ROUTING, SETTING_KEY_ROUTING_STRATEGY) || res
+	res = n.ApplySettings(SETTING_CATEGORY_ROUTING, SETTING_KEY_PEER_RETRY_AFTER) || res
+	res = n.ApplySettings(SETTING_CATEGORY_ROUTING, SETTING_KEY_WRITE_FORWARD) || res
+	res = n.ApplySettings(SETTING_CATEGORY_HEALTH, SETTING_KEY_HEALTH_INTERVAL) || res
+	res = n.ApplySettings(SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_DIR) || res
+	res = n.ApplySettings(SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_INTERVAL) || res
+	res = n.ApplySettings(SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_KEEP) || res
+	res = n.ApplySettings(SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_GZIP) || res
+	res = n.ApplySettings(SETTING_CATEGORY_RELOAD, SETTING_KEY_RELOAD_INTERVAL) || res
 	res = n.ApplySettings(SETTING_CATEGORY_NODES, "no need key") || res
 	return res
 }
@@ -292,25 +543,36 @@ func GetStatusInternal(db SureSQLDB, setNodeStatus bool) (orm.NodeStatusStruct,
 		return orm.NodeStatusStruct{}, err
 	}
 	if setNodeStatus {
-		CurrentNode.Status.DirSize = status.DirSize
-		CurrentNode.Status.DBSize = status.DBSize
-		// CurrentNodeID is not the DBMS NodeID. status.NodeID is the DBMS NodeID (if clustered)
-		// CurrentNode.Status.NodeID = status.NodeID
-		CurrentNode.Status.LastBackup = status.LastBackup
-		CurrentNode.Status.Leader = status.Leader
-		if CurrentNode.Status.MaxPool == 0 {
-			if CurrentNode.MaxPool != 0 {
-				CurrentNode.Status.MaxPool = CurrentNode.MaxPool
-			} else {
-				CurrentNode.Status.MaxPool = DEFAULT_MAX_POOL
-			}
-		}
-		CurrentNode.Status.Uptime = time.Since(ServerStartTime) // this is refreshed when Status handler is called
-
+		updateNode(func(n *SureSQLNode) error {
+			n.applyDBMSStatus(&status)
+			return nil
+		})
 	}
 	return status, err
 }
 
+// Put the DBMS status in the node status, the caller holds the node lock
+func (n *SureSQLNode) applyDBMSStatus(status *orm.NodeStatusStruct) {
+	n.Status.DirSize = status.DirSize
+	n.Status.DBSize = status.DBSize
+	// CurrentNodeID is not the DBMS NodeID. status.NodeID is the DBMS NodeID (if clustered)
+	// CurrentNode.Status.NodeID = status.NodeID
+	// Backup is done by SureSQL (rqlite does not report it), keep the latest of both
+	if status.LastBackup.After(n.Status.LastBackup) {
+		n.Status.LastBackup = status.LastBackup
+	}
+	status.LastBackup = n.Status.LastBackup
+	n.Status.Leader = status.Leader
+	if n.Status.MaxPool == 0 {
+		if n.MaxPool != 0 {
+			n.Status.MaxPool = n.MaxPool
+		} else {
+			n.Status.MaxPool = DEFAULT_MAX_POOL
+		}
+	}
+	n.Status.Uptime = time.Since(ServerStartTime) // this is refreshed when Status handler is called
+}
+
 // Print the node information for console log
 func (n SureSQLNode) PrintWelcomePretty() {
 	fmt.Printf("")
@@ -344,6 +606,10 @@ func (n SureSQLNode) PrintWelcomePretty() {
 	if n.InternalConfig.JWEKey != "" {
 		hardjwe = true
 	}
+	routing := "none"
+	if n.Config.IsSplitWrite && n.Router != nil {
+		routing = n.Router.Strategy()
+	}
 	consistency := n.InternalConfig.Consistency
 	if consistency == "" {
 		consistency = "default"
@@ -382,6 +648,10 @@ func (n SureSQLNode) PrintWelcomePretty() {
 	appSettings := []print.KeyValue{
 		print.Content(false, false, "Mode", n.Config.Mode),
 		print.Content(false, false, "Split-write", n.Config.IsSplitWrite),
+		print.Content(false, false, "Routing", routing),
+		print.Content(false, false, "Write forward", n.WriteForward),
+		print.Content(false, false, "Backup dir", n.BackupDir),
+		print.Content(false, false, "Backup every", n.BackupInterval),
 		print.Content(false, false, "IP", n.Config.IP),
 		print.Content(false, false, "DB init", n.Config.IsInitDone),
 		print.Content(false, false, "Pool", n.IsPoolEnabled),
@@ -0,0 +1,106 @@
+package suresql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/medatechnology/simpleorm/rqlite"
+)
+
+const (
+	DBMS_EXECUTE_TRANSACTION_ENDPOINT = "/db/execute?transaction"
+)
+
+// Direct HTTP call to the DBMS (rqlite) for the API that is not in orm.Database, ie: /nodes, /db/backup, /db/load.
+// Uses the same URL and credentials as the internal connection. Caller must close the body.
+func DBMSRequest(conf SureSQLDBMSConfig, method, endpoint string, body io.Reader, contentType string) (*http.Response, error) {
+	conf.GenerateRQLiteURL()
+	client := &http.Client{Timeout: conf.HttpTimeout}
+	return dbmsRequest(client, conf.URL, conf.Username, conf.Password, method, endpoint, body, contentType)
+}
+
+// Same as DBMSRequest using the URL, credentials and HTTP client of the connection (ie: the user's or a peer's)
+func connectionRequest(db *rqlite.RQLiteDirectDB, method, endpoint string, body io.Reader, contentType string) (*http.Response, error) {
+	client := db.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: db.Config.Timeout}
+	}
+	return dbmsRequest(client, db.Config.URL, db.Config.Username, db.Config.Password, method, endpoint, body, contentType)
+}
+
+func dbmsRequest(client *http.Client, url, username, password, method, endpoint string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(url, "/")+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("DBMS %s returns status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Execute the statements in one transaction (rqlite /db/execute?transaction), if one fails none is applied.
+// Returns the index of the failed statement, -1 if the error is not from a statement.
+func DBMSExecuteTransaction(conf SureSQLDBMSConfig, commands []string) (int, error) {
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return -1, err
+	}
+	resp, err := DBMSRequest(conf, http.MethodPost, DBMS_EXECUTE_TRANSACTION_ENDPOINT, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return -1, err
+	}
+	return decodeTransactionResponse(resp)
+}
+
+// Same as DBMSExecuteTransaction on the connection (ie: the user's), RoutedDB sends it to the write node.
+// Returns ErrTransactionNotSupported if the connection is not rqlite.
+func ExecuteTransaction(db SureSQLDB, commands []string) (int, error) {
+	switch d := db.(type) {
+	case RoutedDB:
+		return routeWrite(d, func(db SureSQLDB) (int, error) {
+			return ExecuteTransaction(db, commands)
+		})
+	case *rqlite.RQLiteDirectDB:
+		body, err := json.Marshal(commands)
+		if err != nil {
+			return -1, err
+		}
+		resp, err := connectionRequest(d, http.MethodPost, DBMS_EXECUTE_TRANSACTION_ENDPOINT, bytes.NewReader(body), "application/json")
+		if err != nil {
+			return -1, err
+		}
+		return decodeTransactionResponse(resp)
+	}
+	return -1, ErrTransactionNotSupported
+}
+
+func decodeTransactionResponse(resp *http.Response) (int, error) {
+	defer resp.Body.Close()
+	var execResp rqlite.ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&execResp); err != nil {
+		return -1, fmt.Errorf("failed to decode execute response: %w", err)
+	}
+	for i, r := range execResp.Results {
+		if r.Error != "" {
+			return i, fmt.Errorf("%s", r.Error)
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,98 @@
+package driver
+
+import (
+	sqldriver "database/sql/driver"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/medatechnology/suresql"
+
+	orm "github.com/medatechnology/simpleorm"
+)
+
+// rows is the whole query response, the server does not stream the records
+type rows struct {
+	columns []suresql.ColumnInfo
+	records []orm.DBRecord
+	next    int
+}
+
+// Columns are in the order of the SELECT from the columns metadata, database/sql scans them by position.
+// Without it the order of the values is not known, so records without columns are an error.
+func newRows(resp suresql.QueryResponse) (*rows, error) {
+	if len(resp.Records) > 0 && len(resp.Columns) == 0 {
+		return nil, ErrNoColumns
+	}
+	columns := append([]suresql.ColumnInfo{}, resp.Columns...)
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].Order < columns[j].Order })
+	return &rows{columns: columns, records: resp.Records}, nil
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func (r *rows) Close() error {
+	r.next = len(r.records)
+	return nil
+}
+
+func (r *rows) Next(dest []sqldriver.Value) error {
+	if r.next >= len(r.records) {
+		return io.EOF
+	}
+	data := r.records[r.next].Data
+	r.next++
+	for i, c := range r.columns {
+		if i >= len(dest) {
+			break
+		}
+		dest[i] = driverValue(data[c.Name], isRealType(c.Type))
+	}
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName is the declared type of the column, empty for expressions
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.columns[index].Type)
+}
+
+// Nullable is only known for the columns of the table
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	if r.columns[index].Nullable == nil {
+		return false, false
+	}
+	return *r.columns[index].Nullable, true
+}
+
+// REAL affinity of SQLite, 2.0 is sent as 2 in JSON so the declared type decides
+func isRealType(declared string) bool {
+	upper := strings.ToUpper(declared)
+	return strings.Contains(upper, "REAL") || strings.Contains(upper, "FLOA") || strings.Contains(upper, "DOUB")
+}
+
+// JSON values into the driver.Value types: int64, float64, bool, string, nil
+func driverValue(v interface{}, isReal bool) sqldriver.Value {
+	switch val := v.(type) {
+	case nil, string, bool:
+		return val
+	case json.Number:
+		if i, err := val.Int64(); err == nil && !isReal {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case float64:
+		return val
+	default:
+		// Nested JSON (should not happen for SQLite columns) is returned as text
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/medatechnology/goutil v0.0.7
 	github.com/medatechnology/simplehttp v0.0.3
 	github.com/medatechnology/simpleorm v0.0.2
+	golang.org/x/term v0.31.0
 )
 
 require (
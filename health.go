@@ -0,0 +1,144 @@
+package suresql
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// Background health check of the peers: pingpong to the peer SureSQL node and status of the peer DBMS.
+// Result is kept in the router (PeerHealth) so reads are not routed to a peer that is down, and it is
+// returned with the status. NOTE: orm.StatusStruct has no field for latency or up/down, so it is not there.
+const (
+	DEFAULT_HEALTH_INTERVAL = 30 * time.Second
+	HEALTH_PROBE_TIMEOUT    = 5 * time.Second
+	PINGPONG_PATH           = "/db/pingpong"
+
+	// Same header as the server middleware, pingpong needs API key and client ID
+	API_KEY_HEADER   = "API_KEY"
+	CLIENT_ID_HEADER = "CLIENT_ID"
+)
+
+var (
+	healthStop chan struct{}
+	healthLock sync.Mutex
+)
+
+// Status response with the health of the peers
+type NodeStatusResponse struct {
+	orm.NodeStatusStruct
+	PeersHealth []PeerHealth  `json:"peers_health,omitempty"`
+	Cluster     *ClusterDrift `json:"cluster,omitempty"` // DBMS cluster vs nodes settings
+	Backup      *BackupStatus `json:"backup,omitempty"`  // scheduled backups
+}
+
+func (n SureSQLNode) StatusWithHealth(status orm.NodeStatusStruct) NodeStatusResponse {
+	resp := NodeStatusResponse{NodeStatusStruct: status}
+	if n.Router != nil {
+		resp.PeersHealth = n.Router.Peers()
+	}
+	resp.Backup = n.BackupStatus()
+	return resp
+}
+
+// Start the prober in the background, interval is read every round so the setting can be changed at run-time.
+// Interval 0 means disabled, checked again after DEFAULT_HEALTH_INTERVAL.
+func (n *SureSQLNode) StartHealthProber() {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	if healthStop != nil {
+		return
+	}
+	healthStop = make(chan struct{})
+	stop := healthStop
+	go func() {
+		for {
+			node := Node()
+			interval := node.HealthInterval
+			if interval > 0 {
+				node.ProbePeers()
+			} else {
+				interval = DEFAULT_HEALTH_INTERVAL
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (n *SureSQLNode) StopHealthProber() {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	if healthStop != nil {
+		close(healthStop)
+		healthStop = nil
+	}
+}
+
+// Check all peers once, in parallel so one slow peer does not delay the others
+func (n *SureSQLNode) ProbePeers() {
+	if n.Router == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, p := range n.Router.Peers() {
+		wg.Add(1)
+		go func(p PeerHealth) {
+			defer wg.Done()
+			n.probePeer(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (n *SureSQLNode) probePeer(p PeerHealth) {
+	// SureSQL node
+	start := time.Now()
+	err := pingPeer(n.PeerURL(p.URL), n.Config.APIKey, n.Config.ClientID)
+	n.Router.SetNodeHealth(p.NodeNumber, err == nil, time.Since(start))
+	if err != nil {
+		simplelog.LogErrorStr("health", err, "pingpong failed for peer "+p.URL)
+	}
+
+	// DBMS of the peer
+	db, err := n.Router.connection(p, n.InternalConfig)
+	if err != nil {
+		n.Router.MarkDown(p.NodeNumber, err)
+		return
+	}
+	start = time.Now()
+	status, err := db.Status()
+	if err != nil {
+		n.Router.MarkDown(p.NodeNumber, err)
+		return
+	}
+	n.Router.MarkUp(p.NodeNumber, time.Since(start))
+	n.Router.SetDBMSStatus(p.NodeNumber, status.StatusStruct)
+}
+
+func pingPeer(url, apiKey, clientID string) error {
+	req, err := http.NewRequest(http.MethodGet, url+PINGPONG_PATH, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(API_KEY_HEADER, apiKey)
+	req.Header.Set(CLIENT_ID_HEADER, clientID)
+	client := http.Client{Timeout: HEALTH_PROBE_TIMEOUT}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pingpong %s returns status %d", url, resp.StatusCode)
+	}
+	return nil
+}
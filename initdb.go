@@ -5,7 +5,6 @@ import (
 
 	orm "github.com/medatechnology/simpleorm"
 
-	"github.com/medatechnology/goutil/filesystem"
 	"github.com/medatechnology/goutil/print"
 	"github.com/medatechnology/goutil/simplelog"
 )
@@ -15,8 +14,7 @@ const (
 	MIGRATION_UP_FILES_SIGNATURE = "_up.sql"
 )
 
-// This is more like migrating data from MIGRATION_DIRECTORY
-// TODO: fix the printout to use metrics package so we can have the time elapsed information.
+// This is more like migrating data from MIGRATION_DIRECTORY, only the files that are not yet in the migration table.
 // Make sure to call this AFTER connect internal is called!! Because we need the DB connection already.
 func InitDB(force bool) error {
 	// If DB is already init, then do not run again
@@ -26,46 +24,73 @@ func InitDB(force bool) error {
 	}
 
 	simplelog.DEBUG_LEVEL = 1
-	allUpFiles := filesystem.Dir(MIGRATION_DIRECTORY, MIGRATION_UP_FILES_SIGNATURE)
-	fmt.Printf("\nMigration directory has %s files, proceed migration...",
-		print.Colored(fmt.Sprintf("%d", len(allUpFiles)), print.ColorGreen))
-	for _, ef := range allUpFiles {
-		fContent := filesystem.More(MIGRATION_DIRECTORY + ef.Name())
-		sqlCommands := orm.ConvertSQLCommands(fContent)
-		fmt.Printf("Migrating file: %s - lines: %d - commands: %d",
-			print.Colored(ef.Name(), print.ColorBlue), len(fContent), len(sqlCommands))
-		// simplelog.LogFormat("Number of lines : %d", len(fContent))
-
-		// DEBUG: print all the commands
-		// for i, c := range sqlCommands {
-		// 	fmt.Printf("%d:%s\n", i+1, c)
-		// }
-		// res, err := db.ExecManySQL(sqlCommands)
-
-		res, err := CurrentNode.InternalConnection.ExecManySQL(sqlCommands)
-		if err != nil {
-			// NOTE: if one of the file has error, then cannot continue just return. Meaning could potentially initialized partially
-			// TODO: create rollback functionality here.
-			simplelog.LogErr(err, "cannot init migrate")
-			return err
-		}
-		fmt.Printf("%d sql commands executed in : %sms\n", len(res), orm.SecondToMsString(orm.TotalTimeElapsedInSecond(res)))
-
-		// simplelog.LogInfoAny("", 1, commands)
-		// longSQL := strings.Join(fContent[:], "\n")
-		// simplelog.LogThis(longSQL)
-
-		// simplelog.LogThis(fContent...) // print in 1 line
-		// for _, str := range fContent {
-		// simplelog.LogFormat("%s", str)
-		// }
+	if err := MigrateInternal(); err != nil {
+		return err
 	}
 	res := CurrentNode.InternalConnection.ExecOneSQL("UPDATE " + CurrentNode.Config.TableName() + " SET is_init_done=true")
 	if res.Error != nil {
-		// NOTE: if one of the file has error, then cannot continue just return. Meaning could potentially initialized partially
-		// TODO: create rollback functionality here.
 		simplelog.LogErr(res.Error, "cannot update settings table")
 		return res.Error
 	}
 	return nil
 }
+
+// Apply the new files of MIGRATION_DIRECTORY to the internal DB. If the DB was initialized before the migration
+// table existed, the baseline versions are recorded as applied first (not run again).
+func MigrateInternal() error {
+	migrations, err := ReadMigrationFiles(MIGRATION_DIRECTORY)
+	if err != nil {
+		simplelog.LogErr(err, "cannot read migration directory")
+		return err
+	}
+	migrator := CurrentNode.InternalMigrator()
+	if err := BaselineInternal(migrator, migrations); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nMigration directory has %s files, proceed migration...",
+		print.Colored(fmt.Sprintf("%d", len(migrations)), print.ColorGreen))
+	done, err := migrator.Up(migrations)
+	for _, m := range done {
+		fmt.Printf("\nMigrated: %s - commands: %d", print.Colored(m.Version+"_"+m.Name, print.ColorBlue), len(orm.ConvertSQLCommands(m.Up)))
+	}
+	fmt.Printf("\n%d migrations applied\n", len(done))
+	if err != nil {
+		simplelog.LogErr(err, "cannot migrate")
+		return err
+	}
+	return nil
+}
+
+// If the DB was initialized (Config.IsInitDone, read from _configs) before the migration table existed, record
+// the baseline versions as applied so they are not run again. Used by the node start and the migrate command.
+func BaselineInternal(migrator Migrator, migrations []Migration) error {
+	if InternalBaselineVersion() == "" {
+		return nil
+	}
+	if err := migrator.EnsureTable(); err != nil {
+		return err
+	}
+	if applied, err := migrator.Applied(); err == nil && len(applied) == 0 {
+		return migrator.Baseline(migrations, MIGRATION_BASELINE_VERSION)
+	}
+	return nil
+}
+
+// Version that BaselineInternal records, empty if the DB was not initialized before the migration table.
+// Status and dry-run use it as Migrator.AssumedBaseline, so nothing is written.
+func InternalBaselineVersion() string {
+	if !CurrentNode.Config.IsInitDone {
+		return ""
+	}
+	return MIGRATION_BASELINE_VERSION
+}
+
+// Rollback the last n internal migrations with their _down.sql files
+func RollbackInternal(n int) ([]Migration, error) {
+	migrations, err := ReadMigrationFiles(MIGRATION_DIRECTORY)
+	if err != nil {
+		return nil, err
+	}
+	return CurrentNode.InternalMigrator().Down(migrations, n)
+}
@@ -0,0 +1,117 @@
+package suresql
+
+import (
+	"encoding/json"
+	"strconv"
+
+	orm "github.com/medatechnology/simpleorm"
+)
+
+// Keeps track of the response size across multiple results (ie: /querysql with many statements)
+// so the whole response is capped, not only each result.
+type ResponseLimiter struct {
+	MaxRows        int // per result, 0 means no limit
+	MaxBytes       int // for all results, 0 means no limit
+	BytesUsed      int
+	IsTruncated    bool
+	bytesExhausted bool
+}
+
+// Limiter based on the current node settings
+func (n SureSQLNode) NewResponseLimiter() *ResponseLimiter {
+	return &ResponseLimiter{
+		MaxRows:  n.MaxRows,
+		MaxBytes: n.MaxResponseBytes,
+	}
+}
+
+// Returns the limit to put in the query (orm.Condition.Limit), one more than MaxRows so we know
+// if the result is truncated. Client limit is respected if it is lower than MaxRows.
+func (l *ResponseLimiter) QueryLimit(clientLimit int) int {
+	if l.MaxRows <= 0 {
+		return clientLimit
+	}
+	if clientLimit > 0 && clientLimit <= l.MaxRows {
+		return clientLimit
+	}
+	return l.MaxRows + 1
+}
+
+// Cut the records to MaxRows and to whatever bytes left in MaxBytes. Returns the records that fit
+// and whether they are truncated.
+func (l *ResponseLimiter) Limit(records []orm.DBRecord) ([]orm.DBRecord, bool) {
+	for i, r := range records {
+		if !l.Add(i, r) {
+			return records[:i], true
+		}
+	}
+	return records, false
+}
+
+// Add checks if one more record fits in the result that already has kept records, if it fits its size is
+// counted. Once the bytes are used up no record fits anymore, also in the next results. Nil has no limit.
+func (l *ResponseLimiter) Add(kept int, r orm.DBRecord) bool {
+	if l == nil {
+		return true
+	}
+	if l.MaxRows > 0 && kept >= l.MaxRows {
+		l.IsTruncated = true
+		return false
+	}
+	if l.MaxBytes > 0 {
+		size := recordSize(r)
+		if l.bytesExhausted || l.BytesUsed+size > l.MaxBytes {
+			l.bytesExhausted = true
+			l.IsTruncated = true
+			return false
+		}
+		l.BytesUsed += size
+	}
+	return true
+}
+
+// Apply the limit to the QueryResponse, fixing the Count and Truncated flag
+func (l *ResponseLimiter) LimitResponse(resp *QueryResponse) {
+	var truncated bool
+	resp.Records, truncated = l.Limit(resp.Records)
+	resp.Count = len(resp.Records)
+	resp.Truncated = resp.Truncated || truncated
+}
+
+// Size of the record when it is sent as JSON, estimated without encoding it (escaped characters are not counted)
+func recordSize(r orm.DBRecord) int {
+	size := len(`{"TableName":"","Data":{}}`) + len(r.TableName)
+	for k, v := range r.Data {
+		// quotes, colon and comma
+		size += len(k) + 4 + valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v interface{}) int {
+	switch val := v.(type) {
+	case nil:
+		return len("null")
+	case bool:
+		if val {
+			return len("true")
+		}
+		return len("false")
+	case string:
+		return len(val) + 2
+	case float64:
+		return len(strconv.FormatFloat(val, 'g', -1, 64))
+	case json.Number:
+		return len(val)
+	case int:
+		return len(strconv.Itoa(val))
+	case int64:
+		return len(strconv.FormatInt(val, 10))
+	}
+	// Not from the DBMS JSON (ie: nested), rare enough to encode
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
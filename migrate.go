@@ -0,0 +1,442 @@
+package suresql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/object"
+)
+
+// Versioned migrations: file is <version>_<name>_up.sql with optional <version>_<name>_down.sql, ie: 00003_named_queries_up.sql.
+// Applied versions and the checksum of the up file are in the migration table, so only new files are applied.
+const (
+	MIGRATION_TABLE                = "_migrations"
+	MIGRATION_DOWN_FILES_SIGNATURE = "_down.sql"
+	// Databases initialized before versioning have no migration table, these versions were applied by InitDB
+	MIGRATION_BASELINE_VERSION = "00002"
+
+	MIGRATION_UP   = "up"
+	MIGRATION_DOWN = "down"
+)
+
+// One migration from files (or uploaded), with the applied state from the migration table
+type Migration struct {
+	Version   string    `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum,omitempty"`
+	HasDown   bool      `json:"has_down"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at,omitempty"`
+	Drift     bool      `json:"drift,omitempty"`   // up script changed after it was applied
+	Missing   bool      `json:"missing,omitempty"` // applied but the script is not there anymore
+	Up        []string  `json:"-"`                 // lines of the up script
+	Down      []string  `json:"-"`                 // lines of the down script
+}
+
+// Row of the migration table
+type MigrationRecord struct {
+	Version   string `json:"version"     db:"version"`
+	Name      string `json:"name"        db:"name"`
+	Checksum  string `json:"checksum"    db:"checksum"`
+	AppliedAt string `json:"applied_at"  db:"applied_at"`
+}
+
+// Apply and rollback migrations, tracked in Table. Each file runs in one rqlite transaction, with the
+// credentials of Conf if it is set (internal) or on the DB connection (ie: the user's routed connection).
+// A connection that is not rqlite gets the file wrapped in BEGIN/COMMIT.
+// AssumedBaseline is for status and dry-run of a DB that needs the baseline (Baseline is not run): when no
+// version is applied, the versions up to it are taken as applied.
+type Migrator struct {
+	DB              SureSQLDB
+	Table           string
+	Conf            *SureSQLDBMSConfig
+	App             string // client application, if set the table is shared by apps and has the app column
+	AssumedBaseline string
+}
+
+// Migrator for SureSQL internal tables (migrations directory)
+func (n SureSQLNode) InternalMigrator() Migrator {
+	conf := n.InternalConfig
+	return Migrator{DB: n.InternalConnection, Table: MIGRATION_TABLE, Conf: &conf}
+}
+
+// Failed migration file, nothing of the file is applied. Statement is 1-based, 0 if unknown.
+type MigrationError struct {
+	Version   string `json:"version"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"` // up or down
+	Statement int    `json:"statement,omitempty"`
+	SQL       string `json:"sql,omitempty"`
+	Err       string `json:"error"`
+}
+
+func (e MigrationError) Error() string {
+	if e.Statement > 0 {
+		return fmt.Sprintf("migration %s_%s (%s) rolled back, statement %d failed: %s: %s", e.Version, e.Name, e.Direction, e.Statement, e.SQL, e.Err)
+	}
+	return fmt.Sprintf("migration %s_%s (%s) rolled back: %s", e.Version, e.Name, e.Direction, e.Err)
+}
+
+func MigrationChecksum(up []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(up, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Read all up (and matching down) files from the directory ordered by version
+func ReadMigrationFiles(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []Migration
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), MIGRATION_UP_FILES_SIGNATURE) {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), MIGRATION_UP_FILES_SIGNATURE)
+		version, name, _ := strings.Cut(base, "_")
+		up, err := readLines(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := Migration{Version: version, Name: name, Up: up, Checksum: MigrationChecksum(up)}
+		if down, err := readLines(filepath.Join(dir, base+MIGRATION_DOWN_FILES_SIGNATURE)); err == nil {
+			m.Down = down
+			m.HasDown = true
+		}
+		list = append(list, m)
+	}
+	sortMigrations(list)
+	return list, nil
+}
+
+// By version, versions are numbers with or without leading zeros
+func sortMigrations(list []Migration) {
+	sort.Slice(list, func(i, j int) bool { return versionLess(list[i].Version, list[j].Version) })
+}
+
+func versionLess(a, b string) bool {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func readLines(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), nil
+}
+
+func (m Migrator) EnsureTable() error {
+	if m.App != "" {
+		res := m.DB.ExecOneSQL(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			app TEXT,
+			version TEXT,
+			name TEXT,
+			checksum TEXT,
+			applied_at TEXT DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (app, version))`, m.Table))
+		return res.Error
+	}
+	res := m.DB.ExecOneSQL(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version TEXT PRIMARY KEY,
+		name TEXT,
+		checksum TEXT,
+		applied_at TEXT DEFAULT CURRENT_TIMESTAMP)`, m.Table))
+	return res.Error
+}
+
+// Applied migrations from the table by version
+func (m Migrator) Applied() (map[string]MigrationRecord, error) {
+	applied := make(map[string]MigrationRecord)
+	var records []orm.DBRecord
+	var err error
+	if m.App != "" {
+		records, err = m.DB.SelectManyWithCondition(m.Table, &orm.Condition{Field: "app", Operator: "=", Value: m.App})
+	} else {
+		records, err = m.DB.SelectMany(m.Table)
+	}
+	if err != nil {
+		if err == orm.ErrSQLNoRows {
+			return applied, nil
+		}
+		return nil, err
+	}
+	for _, r := range records {
+		rec := object.MapToStruct[MigrationRecord](r.Data)
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// The migration table is made when something is applied, status and plan do not write
+func (m Migrator) tableExists() (bool, error) {
+	_, err := m.DB.SelectOnlyOneSQLParameterized(orm.ParametereizedSQL{
+		Query:  "SELECT name FROM sqlite_master WHERE type='table' AND name=?",
+		Values: []interface{}{m.Table},
+	})
+	if err == orm.ErrSQLNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Merge the migrations with the applied state, applied versions without script are added as missing.
+// Nothing is written, the migration table can be missing.
+func (m Migrator) Status(list []Migration) ([]Migration, error) {
+	exists, err := m.tableExists()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]MigrationRecord)
+	if exists {
+		if applied, err = m.Applied(); err != nil {
+			return nil, err
+		}
+	}
+	if len(applied) == 0 && m.AssumedBaseline != "" {
+		for _, mig := range list {
+			if versionLess(m.AssumedBaseline, mig.Version) {
+				break
+			}
+			applied[mig.Version] = MigrationRecord{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum}
+		}
+	}
+	status := make([]Migration, 0, len(list))
+	for _, mig := range list {
+		if rec, ok := applied[mig.Version]; ok {
+			mig.Applied = true
+			mig.AppliedAt, _ = time.Parse(time.DateTime, rec.AppliedAt)
+			mig.Drift = rec.Checksum != "" && rec.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		status = append(status, mig)
+	}
+	for _, rec := range applied {
+		at, _ := time.Parse(time.DateTime, rec.AppliedAt)
+		status = append(status, Migration{Version: rec.Version, Name: rec.Name, Checksum: rec.Checksum, Applied: true, AppliedAt: at, Missing: true})
+	}
+	sortMigrations(status)
+	return status, nil
+}
+
+// Migrations that Up (n is ignored) or Down (the last n applied, newest first) would run, nothing is executed.
+// Up returns ErrMigrationDrift if an applied migration has changed.
+func (m Migrator) Plan(list []Migration, direction string, n int) ([]Migration, error) {
+	status, err := m.Status(list)
+	if err != nil {
+		return nil, err
+	}
+	var plan []Migration
+	if direction == MIGRATION_DOWN {
+		for i := len(status) - 1; i >= 0 && len(plan) < n; i-- {
+			mig := status[i]
+			if !mig.Applied {
+				continue
+			}
+			if !mig.HasDown {
+				return nil, fmt.Errorf("migration %s_%s has no down script", mig.Version, mig.Name)
+			}
+			plan = append(plan, mig)
+		}
+		return plan, nil
+	}
+	return planUp(status)
+}
+
+// Migrations that redo (Down of the last one then Up) would run, the up plan is made as if the down was done
+func (m Migrator) PlanRedo(list []Migration) ([]Migration, []Migration, error) {
+	down, err := m.Plan(list, MIGRATION_DOWN, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := m.Status(list)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range status {
+		for _, mig := range down {
+			if status[i].Version == mig.Version {
+				status[i].Applied, status[i].Drift = false, false
+			}
+		}
+	}
+	up, err := planUp(status)
+	if err != nil {
+		return nil, nil, err
+	}
+	return down, up, nil
+}
+
+func planUp(status []Migration) ([]Migration, error) {
+	var plan []Migration
+	var drift []string
+	for _, mig := range status {
+		if mig.Drift {
+			drift = append(drift, mig.Version)
+		}
+		if !mig.Applied {
+			plan = append(plan, mig)
+		}
+	}
+	if len(drift) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationDrift, strings.Join(drift, ", "))
+	}
+	return plan, nil
+}
+
+// Apply the migrations that are not yet applied, in order. Does nothing if an applied migration has changed.
+func (m Migrator) Up(list []Migration) ([]Migration, error) {
+	if err := m.EnsureTable(); err != nil {
+		return nil, err
+	}
+	plan, err := m.Plan(list, MIGRATION_UP, 0)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range plan {
+		if err := m.apply(mig, MIGRATION_UP, orm.ConvertSQLCommands(mig.Up), m.recordSQL(mig)); err != nil {
+			return done, err
+		}
+		mig.Applied = true
+		mig.AppliedAt = time.Now().UTC()
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Rollback the last n applied migrations with their down script, newest first
+func (m Migrator) Down(list []Migration, n int) ([]Migration, error) {
+	if err := m.EnsureTable(); err != nil {
+		return nil, err
+	}
+	plan, err := m.Plan(list, MIGRATION_DOWN, n)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range plan {
+		if err := m.apply(mig, MIGRATION_DOWN, orm.ConvertSQLCommands(mig.Down), m.unrecordSQL(mig)); err != nil {
+			return done, err
+		}
+		mig.Applied = false
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Mark the migrations up to the version as applied without running them
+func (m Migrator) Baseline(list []Migration, upTo string) error {
+	if err := m.EnsureTable(); err != nil {
+		return err
+	}
+	for _, mig := range list {
+		if versionLess(upTo, mig.Version) {
+			break
+		}
+		if err := m.record(mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run the commands and the record statement of the migration table atomically, so the table is only
+// changed if the whole file is applied.
+func (m Migrator) apply(mig Migration, direction string, commands []string, record string) error {
+	all := append(commands, record)
+	var failed int
+	var err error
+	if m.Conf != nil {
+		failed, err = DBMSExecuteTransaction(*m.Conf, all)
+	} else {
+		failed, err = ExecuteTransaction(m.DB, all)
+	}
+	if err == nil {
+		return nil
+	}
+	if err != ErrTransactionNotSupported {
+		merr := MigrationError{Version: mig.Version, Name: mig.Name, Direction: direction, Err: err.Error()}
+		if failed >= 0 {
+			merr.Statement = failed + 1
+			merr.SQL = all[failed]
+		}
+		return merr
+	}
+
+	_, err = m.DB.ExecManySQL(append(append([]string{"BEGIN"}, all...), "COMMIT"))
+	if err != nil {
+		m.DB.ExecOneSQL("ROLLBACK")
+		return MigrationError{Version: mig.Version, Name: mig.Name, Direction: direction, Err: err.Error()}
+	}
+	return nil
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (m Migrator) record(mig Migration) error {
+	res := m.DB.ExecOneSQL(m.recordSQL(mig))
+	return res.Error
+}
+
+// Statement to mark the migration as applied, run in the same transaction as the migration
+func (m Migrator) recordSQL(mig Migration) string {
+	if m.App != "" {
+		return fmt.Sprintf("INSERT OR REPLACE INTO %s (app, version, name, checksum) VALUES (%s, %s, %s, %s)",
+			m.Table, sqlQuote(m.App), sqlQuote(mig.Version), sqlQuote(mig.Name), sqlQuote(mig.Checksum))
+	}
+	return fmt.Sprintf("INSERT OR REPLACE INTO %s (version, name, checksum) VALUES (%s, %s, %s)",
+		m.Table, sqlQuote(mig.Version), sqlQuote(mig.Name), sqlQuote(mig.Checksum))
+}
+
+func (m Migrator) unrecordSQL(mig Migration) string {
+	if m.App != "" {
+		return fmt.Sprintf("DELETE FROM %s WHERE app = %s AND version = %s", m.Table, sqlQuote(m.App), sqlQuote(mig.Version))
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.Table, sqlQuote(mig.Version))
+}
+
+// Create empty up and down files with the next version, returns the file names
+func CreateMigrationFiles(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+	list, err := ReadMigrationFiles(dir)
+	if err != nil {
+		return "", "", err
+	}
+	next := 1
+	if len(list) > 0 {
+		last, err := strconv.Atoi(list[len(list)-1].Version)
+		if err != nil {
+			return "", "", fmt.Errorf("last migration version %s is not a number", list[len(list)-1].Version)
+		}
+		next = last + 1
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%05d_%s", next, name))
+	up, down := base+MIGRATION_UP_FILES_SIGNATURE, base+MIGRATION_DOWN_FILES_SIGNATURE
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- rollback of "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS _named_queries;
+DELETE FROM _settings WHERE category='access' AND setting_key IN ('raw_sql_deny_roles', 'raw_sql_deny_users');
@@ -0,0 +1,16 @@
+-- Registry of named parameterized queries, clients call /db/api/named/{name} with the values only
+-- query_type: query (select, returns records) or exec (insert, update, delete)
+CREATE TABLE IF NOT EXISTS _named_queries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT UNIQUE,
+  query TEXT,
+  query_type TEXT,
+  description TEXT,
+  created_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Switch to disable raw SQL endpoints (/db/api/sql and /db/api/querysql) per role or per user
+-- text_value is comma separated, ie: 'mobile,guest'. Empty means everyone can use raw SQL.
+INSERT INTO _settings(category, data_type, setting_key, text_value) VALUES
+('access', 'string', 'raw_sql_deny_roles', ''),
+('access', 'string', 'raw_sql_deny_users', '');
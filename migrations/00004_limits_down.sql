@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='limit' AND setting_key IN ('max_rows', 'max_response_bytes');
@@ -0,0 +1,4 @@
+-- Server-side caps for query responses (/db/api/query and /db/api/querysql), 0 means no limit.
+-- When the cap is reached the response has "truncated": true
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES ('limit', 'int', 'max_rows', 10000);
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES ('limit', 'int', 'max_response_bytes', 16777216); -- 16MB
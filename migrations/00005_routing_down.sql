@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='routing' AND setting_key IN ('strategy', 'peer_retry');
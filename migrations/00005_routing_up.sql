@@ -0,0 +1,4 @@
+-- Read/write split routing, only used if _configs.is_split_write is true
+-- strategy: round_robin or least_latency. peer_retry: seconds before a peer that is down is tried again
+INSERT INTO _settings(category, data_type, setting_key, text_value) VALUES ('routing', 'string', 'strategy', 'round_robin');
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES ('routing', 'int', 'peer_retry', 30);
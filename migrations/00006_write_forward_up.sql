@@ -0,0 +1,2 @@
+-- How a follower node handles /sql and /insert: proxy (to leader), redirect (307 to leader) or off
+INSERT INTO _settings(category, data_type, setting_key, text_value) VALUES ('routing', 'string', 'write_forward', 'proxy');
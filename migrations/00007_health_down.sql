@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='health' AND setting_key='interval';
@@ -0,0 +1,2 @@
+-- Background health check of the peers (pingpong and DBMS status), in seconds. 0 means disabled
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES ('health', 'int', 'interval', 30);
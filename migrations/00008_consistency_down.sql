@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='access' AND setting_key='consistency_deny_roles';
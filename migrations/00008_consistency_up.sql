@@ -0,0 +1,4 @@
+-- Roles that cannot request linearizable or strong consistency per request (both go through the leader)
+-- text_value is comma separated, ie: 'dashboard,guest'. Empty means every role can.
+INSERT INTO _settings(category, data_type, setting_key, text_value) VALUES
+('access', 'string', 'consistency_deny_roles', '');
@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='backup' AND setting_key='dir';
@@ -0,0 +1,3 @@
+-- Local directory for the backup files of /suresql/backup?save=true, empty means backup is only streamed
+INSERT INTO _settings(category, data_type, setting_key, text_value) VALUES
+('backup', 'string', 'dir', '');
@@ -0,0 +1,6 @@
+-- Scheduled backups to backup.dir: interval in minutes (0 disabled), keep the newest N files (0 keep all),
+-- gzip compresses the files (bool is int). Result of each run is in _access_logs (action_type scheduled_backup).
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES
+('backup', 'int', 'interval', 0),
+('backup', 'int', 'keep', 7),
+('backup', 'bool', 'gzip', 0);
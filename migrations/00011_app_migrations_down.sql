@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS _app_migration_scripts;
+DROP TABLE IF EXISTS _app_migrations;
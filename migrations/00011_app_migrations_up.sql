@@ -0,0 +1,11 @@
+-- Schema migrations of the client applications, uploaded with /db/api/migrations.
+-- Applied versions are tracked per app in _app_migrations (created by SureSQL like _migrations).
+CREATE TABLE IF NOT EXISTS _app_migration_scripts (
+  app TEXT,
+  version TEXT,
+  name TEXT,
+  up_sql TEXT,
+  down_sql TEXT,
+  created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (app, version)
+);
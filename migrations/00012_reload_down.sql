@@ -0,0 +1 @@
+DELETE FROM _settings WHERE category='reload' AND setting_key='interval';
@@ -0,0 +1,4 @@
+-- Reload _configs and _settings every interval seconds without restart, 0 (default) disables the polling.
+-- POST /suresql/reload reloads them at once.
+INSERT INTO _settings(category, data_type, setting_key, int_value) VALUES
+('reload', 'int', 'interval', 0);
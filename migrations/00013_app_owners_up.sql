@@ -0,0 +1,8 @@
+-- Owner of the app migrations, the first upload makes the caller the owner (the user, or the role with ?owner=role).
+-- Only the owner can upload, list, apply and roll back the migrations of the app.
+CREATE TABLE IF NOT EXISTS _app_owners (
+  app TEXT PRIMARY KEY,
+  owner_user TEXT,
+  owner_role TEXT,
+  created_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
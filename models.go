@@ -21,20 +21,33 @@ const (
 	DEFAULT_RETRY_TIMEOUT = 60 * time.Second
 	DEFAULT_RETRY         = 3
 
+	// Default response limits, 0 means no limit
+	DEFAULT_MAX_ROWS           = 10000
+	DEFAULT_MAX_RESPONSE_BYTES = 16 * 1024 * 1024 // 16MB
+
+	// Default for follower receiving writes
+	DEFAULT_WRITE_FORWARD = WRITE_FORWARD_PROXY
+
 	// Default Pool settings
 	DEFAULT_MAX_POOL     = 25
 	DEFAULT_POOL_ENABLED = true
+
+	// Named query types
+	NAMED_QUERY_TYPE_QUERY = "query" // select, returns records
+	NAMED_QUERY_TYPE_EXEC  = "exec"  // insert, update, delete, returns rows affected
 )
 
 // GLOBAL VAR
 var (
-	CurrentNode       SureSQLNode
-	ReloadEnvironment bool = false
+	CurrentNode SureSQLNode
 
 	// Standard error, cannot use constant on struct
 	// Should be constant instead?
-	ErrNoDBConnection       medaerror.MedaError = medaerror.MedaError{Message: "no db connection"}
-	ErrDBInitializedAlready medaerror.MedaError = medaerror.MedaError{Message: "DB already initialized"}
+	ErrNoDBConnection          medaerror.MedaError = medaerror.MedaError{Message: "no db connection"}
+	ErrDBInitializedAlready    medaerror.MedaError = medaerror.MedaError{Message: "DB already initialized"}
+	ErrMigrationDrift          medaerror.MedaError = medaerror.MedaError{Message: "applied migration has changed"}
+	ErrAppNotOwner             medaerror.MedaError = medaerror.MedaError{Message: "not the owner of the app migrations"}
+	ErrTransactionNotSupported medaerror.MedaError = medaerror.MedaError{Message: "connection cannot run a transaction"}
 	// ErrTokenNotFound  medaerror.MedaError = medaerror.MedaError{Message: "token not found"}
 	// ErrInvalidRequest medaerror.MedaError = medaerror.MedaError{Message: "invalid request param or body"}
 	// ErrWrongPassword  medaerror.MedaError = medaerror.MedaError{Message: "password missmatch"}
@@ -54,9 +67,11 @@ type StandardResponse struct {
 // ===== Used in handle_SQL endpoints
 // SQLRequest represents the request structure for executing SQL commands: UPDATE, DELETE, DROP, INSERT, SELECT
 type SQLRequest struct {
-	Statements []string                `json:"statements,omitempty"` // Raw SQL statements to execute
-	ParamSQL   []orm.ParametereizedSQL `json:"param_sql,omitempty"`  // Parameterized SQL statements to execute
-	SingleRow  bool                    `json:"single_row,omitempty"` // If true, return only first row
+	Statements     []string                `json:"statements,omitempty"`      // Raw SQL statements to execute
+	ParamSQL       []orm.ParametereizedSQL `json:"param_sql,omitempty"`       // Parameterized SQL statements to execute
+	SingleRow      bool                    `json:"single_row,omitempty"`      // If true, return only first row
+	IncludeColumns bool                    `json:"include_columns,omitempty"` // Only for /querysql, if true add columns metadata in each result
+	Consistency    string                  `json:"consistency,omitempty"`     // Optional read consistency for this request only: none, weak, linearizable, strong
 }
 
 // SQLResponse represents the response structure for SQL execution results
@@ -69,9 +84,11 @@ type SQLResponse struct {
 // ===== Used in handle_Query endpoints
 // QueryRequest represents the simplified request structure for executing SELECT queries
 type QueryRequest struct {
-	Table     string         `json:"table"`                // Table name for queries
-	Condition *orm.Condition `json:"condition,omitempty"`  // Optional condition for filtering
-	SingleRow bool           `json:"single_row,omitempty"` // If true, return only first row
+	Table          string         `json:"table"`                     // Table name for queries
+	Condition      *orm.Condition `json:"condition,omitempty"`       // Optional condition for filtering
+	SingleRow      bool           `json:"single_row,omitempty"`      // If true, return only first row
+	IncludeColumns bool           `json:"include_columns,omitempty"` // If true add columns metadata (name, type, nullable, order)
+	Consistency    string         `json:"consistency,omitempty"`     // Optional read consistency for this request only: none, weak, linearizable, strong
 }
 
 // QueryResponse represents the response structure for query results
@@ -79,6 +96,8 @@ type QueryResponse struct {
 	Records       []orm.DBRecord `json:"records"` // Always returns as array, even for single record
 	ExecutionTime float64        `json:"execution_time"`
 	Count         int            `json:"count"`
+	Truncated     bool           `json:"truncated"`         // true if records are cut because of max_rows or max_response_bytes
+	Columns       []ColumnInfo   `json:"columns,omitempty"` // only if requested with include_columns
 }
 
 // QueryRequest represents the simplified request structure for executing SELECT queries
@@ -105,6 +124,13 @@ type InsertRequest struct {
 	SameTable bool           `json:"same_table,omitempty"` // Indicates if all records belong to the same table
 }
 
+// ===== Used in handle_Named endpoints
+// NamedQueryRequest represents the request structure for executing a stored (named) query
+type NamedQueryRequest struct {
+	Values    []interface{} `json:"values,omitempty"`     // Values for the placeholders in the stored query
+	SingleRow bool          `json:"single_row,omitempty"` // If true, return only first row (query type only)
+}
+
 // Originally this was saved in DB as table, but maybe Redis or some auto-expire system is better
 type TokenTable struct {
 	ID               string    `json:"id,omitempty"                  db:"id"`
@@ -116,12 +142,44 @@ type TokenTable struct {
 	CreatedAt        time.Time `json:"created_at,omitempty"          db:"created_at"`
 	// additional members
 	UserName string
+	RoleName string
 }
 
 func (t TokenTable) TableName() string {
 	return "_tokens"
 }
 
+// Registry of named parameterized queries, clients only send the name and the values.
+// QueryType is either NAMED_QUERY_TYPE_QUERY (select) or NAMED_QUERY_TYPE_EXEC (insert, update, delete).
+type NamedQueryTable struct {
+	ID          int       `json:"id,omitempty"            db:"id"`
+	Name        string    `json:"name,omitempty"          db:"name"`
+	Query       string    `json:"query,omitempty"         db:"query"`
+	QueryType   string    `json:"query_type,omitempty"    db:"query_type"`
+	Description string    `json:"description,omitempty"   db:"description"`
+	CreatedAt   time.Time `json:"created_at,omitempty"    db:"created_at"`
+}
+
+func (q NamedQueryTable) TableName() string {
+	return "_named_queries"
+}
+
+// Return the stored query as ParametereizedSQL with the values from client
+func (q NamedQueryTable) ToParameterized(values []interface{}) orm.ParametereizedSQL {
+	return orm.ParametereizedSQL{
+		Query:  q.Query,
+		Values: values,
+	}
+}
+
+// If query_type is empty, guess from the first keyword of the query
+func (q NamedQueryTable) IsQuery() bool {
+	if q.QueryType != "" {
+		return q.QueryType == NAMED_QUERY_TYPE_QUERY
+	}
+	return GuessNamedQueryType(q.Query) == NAMED_QUERY_TYPE_QUERY
+}
+
 // This is reserved to be configuration that usually taken from environment variables for safety
 type EnvConfig struct {
 	Token        string        `json:"token,omitempty"           db:"token"`
@@ -249,6 +307,16 @@ type SureSQLNode struct {
 	MaxPool            int                  `json:"max_pool,omitempty"             db:"max_pool"`            // total nodes for this project
 	IsPoolEnabled      bool                 `json:"is_poolenabled,omitempty"       db:"is_poolenabled"`      // if this DB already initialized
 	IsEncrypted        bool                 `json:"is_encrypted,omitempty"         db:"is_encrypted"`        // none/AES/Bcrypt (already in Settings)
+	MaxRows            int                  `json:"max_rows,omitempty"             db:"max_rows"`            // max rows per query response, 0 no limit
+	MaxResponseBytes   int                  `json:"max_response_bytes,omitempty"   db:"max_response_bytes"`  // max records size per query response, 0 no limit
+	Router             *PeerRouter          `json:"router,omitempty"               db:"router"`              // read/write split routing to the peers
+	WriteForward       string               `json:"write_forward,omitempty"        db:"write_forward"`       // proxy, redirect or off, for writes sent to follower
+	HealthInterval     time.Duration        `json:"health_interval,omitempty"      db:"health_interval"`     // how often the peers are checked, 0 disabled
+	BackupDir          string               `json:"backup_dir,omitempty"           db:"backup_dir"`          // local directory for the backup files
+	BackupInterval     time.Duration        `json:"backup_interval,omitempty"      db:"backup_interval"`     // how often the scheduled backup runs, 0 disabled
+	BackupKeep         int                  `json:"backup_keep,omitempty"          db:"backup_keep"`         // number of backup files to keep, 0 keep all
+	BackupGzip         bool                 `json:"backup_gzip,omitempty"          db:"backup_gzip"`         // compress the backup files
+	ReloadInterval     time.Duration        `json:"reload_interval,omitempty"      db:"reload_interval"`     // how often the settings are reloaded, 0 disabled
 	// IP                 string               `json:"ip,omitempty"                   db:"ip"`                  // IP for this sureSQL node
 	// TokenExp           time.Duration        `json:"token_exp,omitempty"            db:"token_exp"`           // token expiration in minutes
 	// RefreshExp         time.Duration        `json:"refresh_exp,omitempty"          db:"refresh_exp"`         // refresh token expiration in minutes
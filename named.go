@@ -0,0 +1,42 @@
+package suresql
+
+import (
+	"strings"
+)
+
+// Statements that are returning records, the rest is considered exec (insert, update, delete, etc)
+var namedQueryReadKeywords = []string{"SELECT", "WITH", "PRAGMA", "EXPLAIN", "VALUES"}
+
+// Guess the type of query from the first keyword, used when query_type is not provided
+func GuessNamedQueryType(query string) string {
+	fields := strings.Fields(strings.TrimSpace(query))
+	if len(fields) == 0 {
+		return NAMED_QUERY_TYPE_EXEC
+	}
+	first := strings.ToUpper(strings.TrimLeft(fields[0], "("))
+	for _, k := range namedQueryReadKeywords {
+		if first == k {
+			return NAMED_QUERY_TYPE_QUERY
+		}
+	}
+	return NAMED_QUERY_TYPE_EXEC
+}
+
+// Check if the user (or the user's role) is allowed to send arbitrary SQL via /sql and /querysql.
+// By default everyone is allowed, unless listed in the access settings.
+func (n SureSQLNode) IsRawSQLAllowed(username, role string) bool {
+	for _, u := range n.Settings.SettingList(SETTING_CATEGORY_ACCESS, SETTING_KEY_RAW_SQL_DENY_USERS) {
+		if u == username {
+			return false
+		}
+	}
+	if role == "" {
+		return true
+	}
+	for _, r := range n.Settings.SettingList(SETTING_CATEGORY_ACCESS, SETTING_KEY_RAW_SQL_DENY_ROLES) {
+		if r == role {
+			return false
+		}
+	}
+	return true
+}
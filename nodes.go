@@ -0,0 +1,126 @@
+package suresql
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// One node of the cluster from the nodes settings, text value is: node_number|hostname|ip|mode[|port]
+// Port is the SureSQL port of the node, empty means the same as this node.
+type NodeSetting struct {
+	Key        string `json:"key,omitempty"` // setting_key, ie: master, peer-01
+	NodeNumber int    `json:"node_number"`
+	Hostname   string `json:"hostname"`
+	IP         string `json:"ip,omitempty"`
+	Mode       string `json:"mode"`
+	Port       string `json:"port,omitempty"`
+	IsThisNode bool   `json:"is_this_node,omitempty"`
+}
+
+// Parse the setting text value, returns error if the format is wrong
+func ParseNodeSetting(s SettingTable) (NodeSetting, error) {
+	parsed := strings.Split(s.TextValue, SETTING_NODE_DELIMITER)
+	if len(parsed) != 4 && len(parsed) != 5 {
+		return NodeSetting{}, fmt.Errorf("node %s: expected node_number|hostname|ip|mode[|port], got %q", s.SettingKey, s.TextValue)
+	}
+	num, err := strconv.Atoi(strings.TrimSpace(parsed[0]))
+	if err != nil {
+		return NodeSetting{}, fmt.Errorf("node %s: invalid node_number %q", s.SettingKey, parsed[0])
+	}
+	ns := NodeSetting{
+		Key:        s.SettingKey,
+		NodeNumber: num,
+		Hostname:   strings.TrimSpace(parsed[1]),
+		IP:         strings.TrimSpace(parsed[2]),
+		Mode:       strings.TrimSpace(parsed[3]),
+	}
+	if len(parsed) == 5 {
+		ns.Port = strings.TrimSpace(parsed[4])
+	}
+	return ns, nil
+}
+
+// Check the node fields, mode must be r, w or rw
+func (ns NodeSetting) Validate() error {
+	if ns.NodeNumber < 0 {
+		return fmt.Errorf("node_number cannot be negative")
+	}
+	if ns.Hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+	if strings.Contains(ns.Hostname, SETTING_NODE_DELIMITER) || strings.Contains(ns.IP, SETTING_NODE_DELIMITER) {
+		return fmt.Errorf("hostname and ip cannot contain %s", SETTING_NODE_DELIMITER)
+	}
+	if ns.IP != "" && net.ParseIP(ns.IP) == nil {
+		return fmt.Errorf("invalid ip %s", ns.IP)
+	}
+	if ns.Port != "" {
+		if port, err := strconv.Atoi(ns.Port); err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %s", ns.Port)
+		}
+	}
+	switch ns.Mode {
+	case NODE_MODE_READ, NODE_MODE_WRITE, NODE_MODE_READ_WRITE:
+	default:
+		return fmt.Errorf("mode must be %s, %s or %s", NODE_MODE_READ, NODE_MODE_WRITE, NODE_MODE_READ_WRITE)
+	}
+	return nil
+}
+
+// Setting row to save in _settings, key is generated from node number if empty
+func (ns NodeSetting) ToSetting() SettingTable {
+	key := ns.Key
+	if key == "" {
+		key = fmt.Sprintf("node-%02d", ns.NodeNumber)
+	}
+	fields := []string{strconv.Itoa(ns.NodeNumber), ns.Hostname, ns.IP, ns.Mode}
+	if ns.Port != "" {
+		fields = append(fields, ns.Port)
+	}
+	return SettingTable{
+		Category:   SETTING_CATEGORY_NODES,
+		DataType:   "string",
+		SettingKey: key,
+		TextValue:  strings.Join(fields, SETTING_NODE_DELIMITER),
+	}
+}
+
+// Hostname and SureSQL port of the node, the port of this node is used if it is not set
+func (ns NodeSetting) Address(defaultPort string) string {
+	port := ns.Port
+	if port == "" {
+		port = defaultPort
+	}
+	if port == "" || strings.Contains(ns.Hostname, "://") {
+		return ns.Hostname
+	}
+	return net.JoinHostPort(ns.Hostname, port)
+}
+
+// All nodes from settings (including this node) ordered by node number, wrong format is skipped
+func (n SureSQLNode) NodeSettings() []NodeSetting {
+	var list []NodeSetting
+	for _, s := range n.Settings[SETTING_CATEGORY_NODES] {
+		ns, err := ParseNodeSetting(s)
+		if err != nil {
+			continue
+		}
+		ns.IsThisNode = ns.NodeNumber == n.Config.NodeNumber
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeNumber < list[j].NodeNumber })
+	return list
+}
+
+// Find the node by node number from settings
+func (n SureSQLNode) NodeSettingExist(nodeNumber int) (NodeSetting, bool) {
+	for _, ns := range n.NodeSettings() {
+		if ns.NodeNumber == nodeNumber {
+			return ns, true
+		}
+	}
+	return NodeSetting{}, false
+}
@@ -0,0 +1,272 @@
+package suresql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	orm "github.com/medatechnology/simpleorm"
+	"github.com/medatechnology/simpleorm/rqlite"
+)
+
+// Result of one SELECT read from the DBMS response (rqlite /db/query) row by row. Rows are only kept while
+// they fit the ResponseLimiter, the rest is read and dropped, so a big result is never held in memory.
+// Columns are in the order of the SELECT with the declared types, the names are the keys of the records.
+type OrderedResult struct {
+	Columns   []ColumnInfo
+	Records   []orm.DBRecord
+	Truncated bool
+	Time      float64
+}
+
+// Run the SELECT statements on the connection, one result per statement. With describe the table info of
+// each statement is read in the same DBMS request for the nullable and primary key of the columns. With
+// RoutedDB the read goes to a peer like the other reads.
+func QueryOrdered(db SureSQLDB, sqls []orm.ParametereizedSQL, limiter *ResponseLimiter, describe bool) ([]OrderedResult, error) {
+	all := sqls
+	var described []int // statement of each table info, they are after the statements
+	if describe {
+		all = append([]orm.ParametereizedSQL{}, sqls...)
+		for i, q := range sqls {
+			if table := tableNameFromSQL(q.Query); table != UNKNOWN_TABLE_NAME {
+				all = append(all, tableInfoSQL(table))
+				described = append(described, i)
+			}
+		}
+	}
+	results, err := queryRouted(db, all, len(sqls), limiter)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) < len(sqls) {
+		return nil, fmt.Errorf("DBMS returns %d results for %d statements", len(results), len(sqls))
+	}
+	for j, i := range described {
+		if len(sqls)+j < len(results) {
+			describeColumns(results[i].Columns, results[len(sqls)+j].Records)
+		}
+	}
+	return results[:len(sqls)], nil
+}
+
+// Only the first limited statements are the client's, the rest (table info) is not capped
+func queryRouted(db SureSQLDB, sqls []orm.ParametereizedSQL, limited int, limiter *ResponseLimiter) ([]OrderedResult, error) {
+	switch d := db.(type) {
+	case RoutedDB:
+		return routeRead(d, func(db SureSQLDB) ([]OrderedResult, error) {
+			// The read can be done again on this node, so the limiter only counts the one that succeeded
+			l := *limiter
+			results, err := queryRouted(db, sqls, limited, &l)
+			if err == nil {
+				*limiter = l
+			}
+			return results, err
+		})
+	case *rqlite.RQLiteDirectDB:
+		return queryDirect(d, sqls, limited, limiter)
+	}
+	return nil, fmt.Errorf("connection %T cannot run ordered query", db)
+}
+
+func queryDirect(db *rqlite.RQLiteDirectDB, sqls []orm.ParametereizedSQL, limited int, limiter *ResponseLimiter) ([]OrderedResult, error) {
+	body, err := json.Marshal(queryStatements(sqls))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal queries: %w", err)
+	}
+	endpoint := rqlite.ENDPOINT_QUERY
+	if db.Config.Consistency != "" {
+		endpoint += "?level=" + url.QueryEscape(db.Config.Consistency)
+	}
+
+	// Same retries as the orm, the request is a read so it can be sent again
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		resp, err = connectionRequest(db, http.MethodPost, endpoint, bytes.NewReader(body), "application/json")
+		if err == nil || attempt+1 >= db.Config.RetryCount {
+			break
+		}
+		time.Sleep(rqlite.DEFAULT_RETRY_TIMEOUT)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return decodeQueryResponse(resp.Body, sqls, limited, limiter)
+}
+
+// rqlite format of parameterized statements: [statement, values...] or [statement, {named values}]
+func queryStatements(sqls []orm.ParametereizedSQL) []interface{} {
+	statements := make([]interface{}, len(sqls))
+	for i, q := range sqls {
+		statement := []interface{}{q.Query}
+		if len(q.Values) == 1 {
+			if named, ok := q.Values[0].(map[string]interface{}); ok {
+				statement = append(statement, named)
+				statements[i] = statement
+				continue
+			}
+		}
+		statements[i] = append(statement, q.Values...)
+	}
+	return statements
+}
+
+// Decode {"results": [{"columns": [], "types": [], "values": [[]], "error": ""}], "time": 0}
+func decodeQueryResponse(body io.Reader, sqls []orm.ParametereizedSQL, limited int, limiter *ResponseLimiter) ([]OrderedResult, error) {
+	dec := json.NewDecoder(body)
+	var results []OrderedResult
+	err := decodeObject(dec, func(key string) error {
+		if key != "results" {
+			return skipValue(dec)
+		}
+		return decodeArray(dec, func() error {
+			table := UNKNOWN_TABLE_NAME
+			if len(results) < len(sqls) {
+				table = tableNameFromSQL(sqls[len(results)].Query)
+			}
+			// Error of the table info only means the columns are not described
+			if len(results) >= limited {
+				result, _, err := decodeQueryResult(dec, table, nil)
+				results = append(results, result)
+				return err
+			}
+			result, queryErr, err := decodeQueryResult(dec, table, limiter)
+			if err != nil {
+				return err
+			}
+			if queryErr != "" {
+				return fmt.Errorf("query error: %s", queryErr)
+			}
+			results = append(results, result)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Returns the error of the statement separately from the error of the decoding
+func decodeQueryResult(dec *json.Decoder, table string, limiter *ResponseLimiter) (OrderedResult, string, error) {
+	result := OrderedResult{Records: []orm.DBRecord{}}
+	var names, types []string
+	var queryErr string
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "columns":
+			if err := dec.Decode(&names); err != nil {
+				return err
+			}
+			names = uniqueColumnNames(names)
+			return nil
+		case "types":
+			return dec.Decode(&types)
+		case "time":
+			return dec.Decode(&result.Time)
+		case "error":
+			return dec.Decode(&queryErr)
+		case "values":
+			// rqlite sends the columns first, they are needed to know the size of the record
+			if len(names) == 0 {
+				return fmt.Errorf("DBMS response has values before columns")
+			}
+			return decodeArray(dec, func() error {
+				var row []interface{}
+				if err := dec.Decode(&row); err != nil {
+					return err
+				}
+				record := orm.DBRecord{TableName: table, Data: make(map[string]interface{}, len(names))}
+				for i, col := range names {
+					if i < len(row) {
+						record.Data[col] = row[i]
+					}
+				}
+				if limiter.Add(len(result.Records), record) {
+					result.Records = append(result.Records, record)
+				} else {
+					result.Truncated = true
+				}
+				return nil
+			})
+		}
+		return skipValue(dec)
+	})
+	result.Columns = resultColumns(names, types)
+	return result, queryErr, err
+}
+
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("DBMS response has unexpected %v", token)
+		}
+		if err := field(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// null is taken as an empty array
+func decodeArray(dec *json.Decoder, item func() error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("DBMS response has %v instead of an array", token)
+	}
+	for dec.More() {
+		if err := item(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode DBMS response: %w", err)
+	}
+	if token != delim {
+		return fmt.Errorf("DBMS response has %v instead of %v", token, delim)
+	}
+	return nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var skip json.RawMessage
+	return dec.Decode(&skip)
+}
+
+// Best effort table name of the SELECT, the word after the first FROM (same as the DBMS driver does)
+func tableNameFromSQL(sql string) string {
+	fields := strings.Fields(strings.ToUpper(sql))
+	for i, f := range fields {
+		if f == "FROM" && i+1 < len(fields) {
+			table := strings.SplitN(fields[i+1], ",", 2)[0]
+			if table = strings.Trim(table, "\"'`[]();"); table != "" {
+				return table
+			}
+		}
+	}
+	return UNKNOWN_TABLE_NAME
+}
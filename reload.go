@@ -0,0 +1,199 @@
+package suresql
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	utils "github.com/medatechnology/goutil"
+	"github.com/medatechnology/goutil/medattlmap"
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// Reload _configs and _settings at run-time, from the internal API or the poller. The poller interval is
+// read every round so the setting can be changed at run-time. Interval 0 means disabled, checked again
+// after RELOAD_POLL_IDLE.
+const (
+	RELOAD_POLL_IDLE = time.Minute
+)
+
+// Result of the reload, Changed is the settings (category.key) that are added, changed or removed
+type ReloadResult struct {
+	Time          time.Time `json:"time"`
+	Duration      float64   `json:"duration"` // in milliseconds
+	Environment   bool      `json:"environment,omitempty"`
+	ConfigChanged bool      `json:"config_changed"`
+	Changed       []string  `json:"changed"`
+}
+
+var (
+	reloadLock     sync.Mutex
+	reloadStop     chan struct{}
+	reloadStopLock sync.Mutex
+	// guards the DBConnections map swap, the map itself is safe for concurrent use
+	poolLock sync.RWMutex
+
+	// Called after every reload (or setting change) with the config before it, the server sets this to
+	// resize the token maps
+	ReloadHook func(before ConfigTable)
+)
+
+// Reload reads _configs and _settings again from the internal DB and applies them to CurrentNode. With env
+// the .env files are read again first, so changed environment overwrites are applied as well.
+func Reload(env bool) (ReloadResult, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	start := time.Now()
+	result := ReloadResult{Time: start, Environment: env, Changed: []string{}}
+	if env {
+		utils.ReloadEnvEach(".env.dev", SURESQL_ENV_FILE)
+	}
+	// Read the settings first, so a DB error does not leave the node half reloaded
+	settings, err := ReadSettingsFromDB(&CurrentNode.InternalConnection)
+	if err != nil {
+		return result, err
+	}
+	var before ConfigTable
+	// A setting saved during the reload is not lost by the swap, it waits for the node lock
+	err = updateNode(func(n *SureSQLNode) error {
+		before = n.Config
+		if err := LoadConfigFromDB(&n.InternalConnection); err != nil {
+			return err
+		}
+		result.Changed = n.Settings.Diff(settings)
+		// Swap, the old map is not changed so the handlers reading it are safe
+		n.Settings = settings
+		n.ApplyAllConfig()
+		n.GetStatusFromSettings(n.InternalConfig)
+		n.ApplyPeersPool()
+		result.ConfigChanged = before != n.Config
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if ReloadHook != nil {
+		ReloadHook(before)
+	}
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return result, nil
+}
+
+// Apply one setting after it is saved or deleted, the same as Reload does for all of them
+func ApplySettingChange(category, key string) bool {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	var before ConfigTable
+	res := false
+	updateNode(func(n *SureSQLNode) error {
+		before = n.Config
+		res = n.ApplySettings(category, key)
+		switch category {
+		case SETTING_CATEGORY_CONNECTION, SETTING_CATEGORY_NODES:
+			// MaxPool is multiplied by the peers, start again from the setting
+			n.ApplySettings(SETTING_CATEGORY_CONNECTION, SETTING_KEY_MAX_POOL)
+			n.ApplyPeersPool()
+		}
+		return nil
+	})
+	if ReloadHook != nil {
+		ReloadHook(before)
+	}
+	return res
+}
+
+// Keys (category.key) that are different between the two settings, sorted
+func (c Settings) Diff(other Settings) []string {
+	changed := []string{}
+	for cat, m := range c {
+		for key, s := range m {
+			if o, ok := other.SettingExist(cat, key); !ok || o != s {
+				changed = append(changed, cat+"."+key)
+			}
+		}
+	}
+	for cat, m := range other {
+		for key := range m {
+			if _, ok := c.SettingExist(cat, key); !ok {
+				changed = append(changed, cat+"."+key)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (n *SureSQLNode) StartReloadPoller() {
+	reloadStopLock.Lock()
+	defer reloadStopLock.Unlock()
+	if reloadStop != nil {
+		return
+	}
+	reloadStop = make(chan struct{})
+	stop := reloadStop
+	go func() {
+		for {
+			interval := Node().ReloadInterval
+			if interval <= 0 {
+				interval = RELOAD_POLL_IDLE
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+			if Node().ReloadInterval <= 0 {
+				continue
+			}
+			result, err := Reload(false)
+			if err != nil {
+				simplelog.LogErrorStr("reload", err, "cannot reload config and settings")
+			} else if len(result.Changed) > 0 || result.ConfigChanged {
+				simplelog.LogFormat("reloaded settings, changed: %v config changed: %t", result.Changed, result.ConfigChanged)
+			}
+		}
+	}()
+}
+
+func (n *SureSQLNode) StopReloadPoller() {
+	reloadStopLock.Lock()
+	defer reloadStopLock.Unlock()
+	if reloadStop != nil {
+		close(reloadStop)
+		reloadStop = nil
+	}
+}
+
+// Replace the pool with a map of the new TTL. Connections keep the expiry of their session (expires by
+// access token), the ones without session are dropped. With nil expires all get the full TTL.
+// Done under the pool lock so a connection added during the copy is not lost.
+func (n *SureSQLNode) ResizeDBConnections(ttl, tick time.Duration, expires map[string]time.Time) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	old := n.DBConnections
+	pool := medattlmap.NewTTLMap(ttl, tick)
+	if old != nil {
+		for token := range old.Map() {
+			val, ok := old.Get(token)
+			if !ok {
+				continue
+			}
+			remaining := ttl
+			if expires != nil {
+				exp, known := expires[token]
+				if !known {
+					continue
+				}
+				remaining = time.Until(exp)
+			}
+			if remaining > 0 {
+				pool.Put(token, remaining, val)
+			}
+		}
+		old.Stop()
+	}
+	n.DBConnections = pool
+}
@@ -0,0 +1,327 @@
+package suresql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+const (
+	DBMS_LOAD_ENDPOINT = "/db/load"
+	SQLITE_FILE_HEADER = "SQLite format 3\x00"
+)
+
+var (
+	// SureSQL cannot start without these tables, a backup without them is not from SureSQL
+	restoreRequiredTables = []string{"_configs", "_settings"}
+
+	// Called after the backup is loaded, the server sets this to revoke the tokens (users can be different)
+	RestoreHook func()
+)
+
+// Result of the restore
+type RestoreResult struct {
+	Format   string  `json:"format"`
+	Gzip     bool    `json:"gzip,omitempty"`
+	Size     int     `json:"size"`     // size loaded to the DBMS (after gunzip)
+	Duration float64 `json:"duration"` // in milliseconds
+}
+
+// Detect the format of the backup from the content: SQLite file (header) or SQL dump (text), gzip is
+// uncompressed first. The tables are read from the schema of the SQLite file or the CREATE TABLE statements
+// of the dump. Returns the uncompressed data.
+func DetectBackup(data []byte) ([]byte, RestoreResult, error) {
+	var result RestoreResult
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, result, fmt.Errorf("invalid gzip backup: %w", err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, result, fmt.Errorf("invalid gzip backup: %w", err)
+		}
+		result.Gzip = true
+	}
+	result.Size = len(data)
+	if len(data) == 0 {
+		return nil, result, fmt.Errorf("backup is empty")
+	}
+
+	var tables map[string]bool
+	var err error
+	if bytes.HasPrefix(data, []byte(SQLITE_FILE_HEADER)) {
+		result.Format = BACKUP_FORMAT_SQLITE
+		tables, err = sqliteTables(data)
+	} else if isText(data) {
+		result.Format = BACKUP_FORMAT_SQL
+		tables, err = sqlDumpTables(data)
+	} else {
+		return nil, result, fmt.Errorf("backup is not a SQLite file or SQL dump")
+	}
+	if err != nil {
+		return nil, result, err
+	}
+	for _, t := range restoreRequiredTables {
+		if !tables[t] {
+			return nil, result, fmt.Errorf("backup has no %s table, it is not a SureSQL backup", t)
+		}
+	}
+	return data, result, nil
+}
+
+func isText(data []byte) bool {
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.IndexByte(head, 0) < 0
+}
+
+// SQL dump from rqlite (or sqlite3 .dump): PRAGMA lines, BEGIN TRANSACTION; then the statements and COMMIT;
+// Returns the tables of the CREATE TABLE statements, they start at the beginning of the line.
+func sqlDumpTables(data []byte) (map[string]bool, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	begin := -1
+	for i, line := range lines {
+		line = strings.ToUpper(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, "PRAGMA ") {
+			continue
+		}
+		if line == "BEGIN TRANSACTION;" || line == "BEGIN;" {
+			begin = i
+		}
+		break
+	}
+	if begin < 0 {
+		return nil, fmt.Errorf("SQL dump must start with BEGIN TRANSACTION")
+	}
+	if last := strings.ToUpper(strings.TrimSpace(lines[len(lines)-1])); last != "COMMIT;" && last != "END;" {
+		return nil, fmt.Errorf("SQL dump must end with COMMIT, it is not complete")
+	}
+
+	tables := make(map[string]bool)
+	for _, line := range lines[begin+1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.EqualFold(fields[0], "CREATE") || !strings.EqualFold(fields[1], "TABLE") {
+			continue
+		}
+		name := fields[2]
+		if len(fields) > 5 && strings.EqualFold(name, "IF") {
+			name = fields[5]
+		}
+		name = strings.SplitN(name, "(", 2)[0]
+		tables[strings.Trim(name, "\"'`[]")] = true
+	}
+	return tables, nil
+}
+
+// Tables of the SQLite file, from the sqlite_schema table (b-tree at page 1). Only the header and the
+// b-tree pages of the schema are read, the file is not opened by SQLite.
+func sqliteTables(data []byte) (map[string]bool, error) {
+	if len(data) < 100 {
+		return nil, fmt.Errorf("SQLite file is shorter than its header")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("SQLite file has invalid page size %d", pageSize)
+	}
+	// payload fractions are fixed by the file format
+	if data[21] != 64 || data[22] != 32 || data[23] != 32 {
+		return nil, fmt.Errorf("SQLite file has invalid header")
+	}
+	if len(data)%pageSize != 0 {
+		return nil, fmt.Errorf("SQLite file size %d is not a multiple of the page size %d, it is not complete", len(data), pageSize)
+	}
+	// Size in pages is only valid when the version-valid-for number is the change counter
+	if binary.BigEndian.Uint32(data[92:96]) == binary.BigEndian.Uint32(data[24:28]) {
+		if pages := int(binary.BigEndian.Uint32(data[28:32])); pages*pageSize != len(data) {
+			return nil, fmt.Errorf("SQLite file has %d pages instead of %d, it is not complete", len(data)/pageSize, pages)
+		}
+	}
+
+	tables := make(map[string]bool)
+	visited := make(map[int]bool)
+	var readPage func(page int) error
+	readPage = func(page int) error {
+		start := (page - 1) * pageSize
+		if page < 1 || start+pageSize > len(data) || visited[page] {
+			return fmt.Errorf("SQLite schema has invalid page %d", page)
+		}
+		visited[page] = true
+		p := data[start : start+pageSize]
+		hdr := 0
+		if page == 1 {
+			hdr = 100
+		}
+		if hdr+12 > len(p) {
+			return fmt.Errorf("SQLite schema page %d is too short", page)
+		}
+		cells := int(binary.BigEndian.Uint16(p[hdr+3 : hdr+5]))
+		switch p[hdr] {
+		case 0x0d: // leaf: payload size, rowid, record
+			for i := 0; i < cells; i++ {
+				offset, err := cellOffset(p, hdr+8, i)
+				if err != nil {
+					return err
+				}
+				_, n := sqliteVarint(p[offset:])
+				_, m := sqliteVarint(p[offset+n:])
+				if n == 0 || m == 0 {
+					return fmt.Errorf("SQLite schema page %d has invalid cell", page)
+				}
+				kind, name, err := schemaRecord(p[offset+n+m:])
+				if err != nil {
+					return err
+				}
+				if kind == "table" {
+					tables[name] = true
+				}
+			}
+		case 0x05: // interior: left child page then key, the right-most child is in the header
+			for i := 0; i < cells; i++ {
+				offset, err := cellOffset(p, hdr+12, i)
+				if err != nil {
+					return err
+				}
+				if offset+4 > len(p) {
+					return fmt.Errorf("SQLite schema page %d has invalid cell", page)
+				}
+				if err := readPage(int(binary.BigEndian.Uint32(p[offset : offset+4]))); err != nil {
+					return err
+				}
+			}
+			return readPage(int(binary.BigEndian.Uint32(p[hdr+8 : hdr+12])))
+		default:
+			return fmt.Errorf("SQLite schema page %d is not a table b-tree page", page)
+		}
+		return nil
+	}
+	if err := readPage(1); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// Offset of cell i in the page, the cell pointers start after the page header
+func cellOffset(p []byte, pointers, i int) (int, error) {
+	at := pointers + 2*i
+	if at+2 > len(p) {
+		return 0, fmt.Errorf("SQLite schema page has invalid cell pointer")
+	}
+	offset := int(binary.BigEndian.Uint16(p[at : at+2]))
+	if offset >= len(p) {
+		return 0, fmt.Errorf("SQLite schema page has invalid cell pointer")
+	}
+	return offset, nil
+}
+
+// The first two columns of the sqlite_schema record: type and name, both are text. The record can go on
+// in overflow pages but these two are always in the cell.
+func schemaRecord(rec []byte) (string, string, error) {
+	headerSize, n := sqliteVarint(rec)
+	if n == 0 || int(headerSize) > len(rec) || int(headerSize) < n {
+		return "", "", fmt.Errorf("SQLite schema has invalid record")
+	}
+	body := int(headerSize)
+	var text [2]string
+	pos := n
+	for i := 0; i < 2; i++ {
+		if pos >= int(headerSize) {
+			return "", "", fmt.Errorf("SQLite schema has invalid record")
+		}
+		serial, m := sqliteVarint(rec[pos:int(headerSize)])
+		if m == 0 || serial < 13 || serial%2 == 0 {
+			return "", "", fmt.Errorf("SQLite schema has invalid record")
+		}
+		pos += m
+		size := int((serial - 13) / 2)
+		if body+size > len(rec) {
+			return "", "", fmt.Errorf("SQLite schema has invalid record")
+		}
+		text[i] = string(rec[body : body+size])
+		body += size
+	}
+	return text[0], text[1], nil
+}
+
+// SQLite varint: big-endian 7 bits per byte, the 9th byte has 8 bits. Returns 0 bytes if it is cut.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// Read the backup file from the backup directory, only the file name is used so it cannot go outside
+func (n SureSQLNode) ReadBackupFile(name string) ([]byte, error) {
+	if n.BackupDir == "" {
+		return nil, fmt.Errorf("backup directory is not set (setting %s.%s)", SETTING_CATEGORY_BACKUP, SETTING_KEY_BACKUP_DIR)
+	}
+	return os.ReadFile(filepath.Join(n.BackupDir, filepath.Base(name)))
+}
+
+// Validate then load the backup to the DBMS (rqlite /db/load), this replaces the whole database.
+// The sessions and the pooled connections are of the users before the restore, they are dropped. The new
+// migration files are applied (the backup can be older), then config and settings are loaded again so this
+// node uses the restored ones.
+func (n *SureSQLNode) Restore(data []byte) (RestoreResult, error) {
+	start := time.Now()
+	data, result, err := DetectBackup(data)
+	if err != nil {
+		return result, err
+	}
+
+	contentType := "application/octet-stream"
+	if result.Format == BACKUP_FORMAT_SQL {
+		contentType = "text/plain"
+	}
+	conf := n.InternalConfig
+	conf.HttpTimeout = BACKUP_TIMEOUT
+	resp, err := DBMSRequest(conf, http.MethodPost, DBMS_LOAD_ENDPOINT, bytes.NewReader(data), contentType)
+	if err != nil {
+		return result, err
+	}
+	resp.Body.Close()
+
+	if RestoreHook != nil {
+		RestoreHook()
+	}
+	n.CloseDBConnections()
+	// Baseline of the migrations is from the restored config, not fatal like at the node start
+	err = updateNode(func(node *SureSQLNode) error {
+		if err := LoadConfigFromDB(&node.InternalConnection); err != nil {
+			return err
+		}
+		return MigrateInternal()
+	})
+	if err != nil {
+		simplelog.LogErrorStr("restore", err, "restored but cannot apply the migrations")
+	}
+
+	if _, err := Reload(false); err != nil {
+		simplelog.LogErrorStr("restore", err, "restored but cannot reload config and settings")
+		return result, fmt.Errorf("restored but cannot reload config and settings: %w", err)
+	}
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return result, nil
+}
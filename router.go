@@ -0,0 +1,414 @@
+package suresql
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/simplelog"
+)
+
+// Read/write split routing across the peers. Only used when Config.IsSplitWrite is on, otherwise all
+// queries go to this node's DBMS (the user's connection). Peers are taken from the nodes settings, the
+// peer's DBMS is reached using the IP (or hostname) of the node with the same port and credentials as
+// the internal connection.
+const (
+	NODE_MODE_READ       = "r"
+	NODE_MODE_WRITE      = "w"
+	NODE_MODE_READ_WRITE = "rw"
+
+	ROUTING_ROUND_ROBIN      = "round_robin"
+	ROUTING_LEAST_LATENCY    = "least_latency"
+	DEFAULT_ROUTING_STRATEGY = ROUTING_ROUND_ROBIN
+	DEFAULT_PEER_RETRY_AFTER = 30 * time.Second // peer that is down is skipped for this long before trying again
+	PEER_MAX_RETRIES         = 1                // fail fast, we have fallback
+)
+
+// Health of the peer as seen by the router and the health prober. IsUp and Latency are for the peer's DBMS
+// (used for routing), IsNodeUp and NodeLatency are for the peer SureSQL node (pingpong).
+type PeerHealth struct {
+	NodeNumber  int               `json:"node_number"`
+	URL         string            `json:"url"`
+	DBMSHost    string            `json:"dbms_host"`
+	Mode        string            `json:"mode"`
+	IsUp        bool              `json:"is_up"`
+	Latency     time.Duration     `json:"latency"`
+	LastSeen    time.Time         `json:"last_seen,omitempty"`
+	DownSince   time.Time         `json:"down_since,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	IsNodeUp    bool              `json:"is_node_up"`
+	NodeLatency time.Duration     `json:"node_latency"`
+	LastChecked time.Time         `json:"last_checked,omitempty"`
+	DBMS        *orm.StatusStruct `json:"dbms,omitempty"` // status of the peer's DBMS from the last check
+}
+
+// Peer is assumed up until proven otherwise, if down then try again after retryAfter
+func (p PeerHealth) isAvailable(retryAfter time.Duration) bool {
+	return p.IsUp || time.Since(p.DownSince) > retryAfter
+}
+
+func (p PeerHealth) canDo(mode string) bool {
+	return strings.Contains(p.Mode, mode)
+}
+
+type PeerRouter struct {
+	mu          sync.Mutex
+	strategy    string
+	retryAfter  time.Duration
+	peers       map[int]*PeerHealth
+	connections map[int]SureSQLDB
+	next        int // round robin counter
+}
+
+func NewPeerRouter() *PeerRouter {
+	return &PeerRouter{
+		strategy:    DEFAULT_ROUTING_STRATEGY,
+		retryAfter:  DEFAULT_PEER_RETRY_AFTER,
+		peers:       make(map[int]*PeerHealth),
+		connections: make(map[int]SureSQLDB),
+	}
+}
+
+// Set the routing settings, empty strategy or 0 retryAfter keeps the current one
+func (r *PeerRouter) Configure(strategy string, retryAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strategy != "" {
+		r.strategy = strategy
+	}
+	if retryAfter > 0 {
+		r.retryAfter = retryAfter
+	}
+}
+
+func (r *PeerRouter) Strategy() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.strategy
+}
+
+// Replace the peers, health of the existing peers is kept. Connection is dropped if the host is changed.
+func (r *PeerRouter) SetPeers(peers []PeerHealth) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tmp := make(map[int]*PeerHealth)
+	for _, p := range peers {
+		if old, ok := r.peers[p.NodeNumber]; ok && old.DBMSHost == p.DBMSHost {
+			old.URL = p.URL
+			old.Mode = p.Mode
+			tmp[p.NodeNumber] = old
+			continue
+		}
+		delete(r.connections, p.NodeNumber)
+		p.IsUp = true
+		peer := p
+		tmp[p.NodeNumber] = &peer
+	}
+	for num := range r.connections {
+		if _, ok := tmp[num]; !ok {
+			delete(r.connections, num)
+		}
+	}
+	r.peers = tmp
+}
+
+// Copy of all peers health, ordered by node number
+func (r *PeerRouter) Peers() []PeerHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []PeerHealth
+	for _, p := range r.peers {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeNumber < list[j].NodeNumber })
+	return list
+}
+
+// Pick the peer that can do the mode (r or w) based on the strategy, returns false if none available
+func (r *PeerRouter) pick(mode string) (PeerHealth, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var candidates []*PeerHealth
+	for _, p := range r.peers {
+		if p.canDo(mode) && p.isAvailable(r.retryAfter) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return PeerHealth{}, false
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].NodeNumber < candidates[j].NodeNumber })
+
+	if r.strategy == ROUTING_LEAST_LATENCY {
+		best := candidates[0]
+		for _, p := range candidates[1:] {
+			if p.Latency < best.Latency {
+				best = p
+			}
+		}
+		return *best, true
+	}
+	r.next++
+	return *candidates[r.next%len(candidates)], true
+}
+
+// Connection to the peer DBMS, created once and reused
+func (r *PeerRouter) connection(p PeerHealth, conf SureSQLDBMSConfig) (SureSQLDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if db, ok := r.connections[p.NodeNumber]; ok {
+		return db, nil
+	}
+	conf.Host = p.DBMSHost
+	conf.MaxRetries = PEER_MAX_RETRIES
+	db, err := NewDatabase(conf)
+	if err != nil {
+		return nil, err
+	}
+	r.connections[p.NodeNumber] = db
+	return db, nil
+}
+
+// Record successful call, latency is averaged so one slow query does not move it too much
+func (r *PeerRouter) MarkUp(node int, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.peers[node]
+	if !ok {
+		return
+	}
+	if !p.IsUp {
+		simplelog.LogFormat("peer %d (%s) is up again", p.NodeNumber, p.URL)
+	}
+	if p.Latency == 0 {
+		p.Latency = latency
+	} else {
+		p.Latency = (p.Latency*3 + latency) / 4
+	}
+	p.IsUp = true
+	p.LastSeen = time.Now()
+	p.LastError = ""
+}
+
+func (r *PeerRouter) MarkDown(node int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.peers[node]
+	if !ok {
+		return
+	}
+	if p.IsUp {
+		simplelog.LogFormat("peer %d (%s) is down: %v", p.NodeNumber, p.URL, err)
+	}
+	p.IsUp = false
+	p.DownSince = time.Now()
+	if err != nil {
+		p.LastError = err.Error()
+	}
+}
+
+// Result of the pingpong to the peer SureSQL node
+func (r *PeerRouter) SetNodeHealth(node int, up bool, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.peers[node]
+	if !ok {
+		return
+	}
+	if p.IsNodeUp != up && !p.LastChecked.IsZero() {
+		simplelog.LogFormat("peer %d (%s) node up: %v", p.NodeNumber, p.URL, up)
+	}
+	p.IsNodeUp = up
+	p.NodeLatency = latency
+	p.LastChecked = time.Now()
+}
+
+// Keep the last DBMS status of the peer
+func (r *PeerRouter) SetDBMSStatus(node int, status orm.StatusStruct) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.peers[node]; ok {
+		p.DBMS = &status
+	}
+}
+
+// Returns the connection for the user's queries. If split-write is off then it is the user's connection.
+func (n *SureSQLNode) RoutedConnection(userDB SureSQLDB) SureSQLDB {
+	if !n.Config.IsSplitWrite || n.Router == nil {
+		return userDB
+	}
+	return RoutedDB{node: n, fallback: userDB}
+}
+
+// RoutedDB sends reads to read-mode peers and writes to the write node. If this node can write then writes
+// stay here. When no peer is available, or the peer fails, it falls back to this node (the user's connection).
+type RoutedDB struct {
+	node        *SureSQLNode
+	fallback    SureSQLDB
+	consistency string // per request consistency, also applied to the peer connection
+}
+
+func (d RoutedDB) peerFor(mode string) (SureSQLDB, int, bool) {
+	if mode == NODE_MODE_WRITE && strings.Contains(d.node.Config.Mode, NODE_MODE_WRITE) {
+		return nil, 0, false
+	}
+	p, ok := d.node.Router.pick(mode)
+	if !ok {
+		return nil, 0, false
+	}
+	db, err := d.node.Router.connection(p, d.node.InternalConfig)
+	if err != nil {
+		d.node.Router.MarkDown(p.NodeNumber, err)
+		return nil, 0, false
+	}
+	return WithConsistency(db, d.consistency), p.NodeNumber, true
+}
+
+// Read on the peer, if it fails then read again on this node. Peer is only marked down if this node
+// succeeded, otherwise it is the query that is wrong not the peer.
+func routeRead[T any](d RoutedDB, fn func(SureSQLDB) (T, error)) (T, error) {
+	db, node, ok := d.peerFor(NODE_MODE_READ)
+	if !ok {
+		return fn(d.fallback)
+	}
+	start := time.Now()
+	res, err := fn(db)
+	if err == nil || err == orm.ErrSQLNoRows {
+		d.node.Router.MarkUp(node, time.Since(start))
+		return res, err
+	}
+	res, ferr := fn(d.fallback)
+	if ferr == nil || ferr == orm.ErrSQLNoRows {
+		d.node.Router.MarkDown(node, err)
+	}
+	return res, ferr
+}
+
+// Writes are not retried on another node, it may already be applied. If the peer does not respond
+// to status then it is marked down so the next write goes somewhere else.
+func routeWrite[T any](d RoutedDB, fn func(SureSQLDB) (T, error)) (T, error) {
+	db, node, ok := d.peerFor(NODE_MODE_WRITE)
+	if !ok {
+		return fn(d.fallback)
+	}
+	start := time.Now()
+	res, err := fn(db)
+	if err == nil {
+		d.node.Router.MarkUp(node, time.Since(start))
+	} else if _, serr := db.Status(); serr != nil {
+		d.node.Router.MarkDown(node, serr)
+	}
+	return res, err
+}
+
+// For the functions that return BasicSQLResult only
+func routeWriteResult(d RoutedDB, fn func(SureSQLDB) orm.BasicSQLResult) orm.BasicSQLResult {
+	res, _ := routeWrite(d, func(db SureSQLDB) (orm.BasicSQLResult, error) {
+		res := fn(db)
+		return res, res.Error
+	})
+	return res
+}
+
+// ===== orm.Database implementation
+
+func (d RoutedDB) GetSchema(hideSQL, hideSureSQL bool) []orm.SchemaStruct {
+	return d.fallback.GetSchema(hideSQL, hideSureSQL)
+}
+
+func (d RoutedDB) Status() (orm.NodeStatusStruct, error) {
+	return d.fallback.Status()
+}
+
+func (d RoutedDB) IsConnected() bool {
+	return d.fallback.IsConnected()
+}
+
+func (d RoutedDB) Leader() (string, error) {
+	return d.fallback.Leader()
+}
+
+func (d RoutedDB) Peers() ([]string, error) {
+	return d.fallback.Peers()
+}
+
+func (d RoutedDB) SelectOne(table string) (orm.DBRecord, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecord, error) { return db.SelectOne(table) })
+}
+
+func (d RoutedDB) SelectMany(table string) (orm.DBRecords, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecords, error) { return db.SelectMany(table) })
+}
+
+func (d RoutedDB) SelectOneWithCondition(table string, condition *orm.Condition) (orm.DBRecord, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecord, error) { return db.SelectOneWithCondition(table, condition) })
+}
+
+func (d RoutedDB) SelectManyWithCondition(table string, condition *orm.Condition) ([]orm.DBRecord, error) {
+	return routeRead(d, func(db SureSQLDB) ([]orm.DBRecord, error) { return db.SelectManyWithCondition(table, condition) })
+}
+
+func (d RoutedDB) SelectOneSQL(sql string) (orm.DBRecords, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecords, error) { return db.SelectOneSQL(sql) })
+}
+
+func (d RoutedDB) SelectManySQL(sqls []string) ([]orm.DBRecords, error) {
+	return routeRead(d, func(db SureSQLDB) ([]orm.DBRecords, error) { return db.SelectManySQL(sqls) })
+}
+
+func (d RoutedDB) SelectOnlyOneSQL(sql string) (orm.DBRecord, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecord, error) { return db.SelectOnlyOneSQL(sql) })
+}
+
+func (d RoutedDB) SelectOneSQLParameterized(paramSQL orm.ParametereizedSQL) (orm.DBRecords, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecords, error) { return db.SelectOneSQLParameterized(paramSQL) })
+}
+
+func (d RoutedDB) SelectManySQLParameterized(paramSQLs []orm.ParametereizedSQL) ([]orm.DBRecords, error) {
+	return routeRead(d, func(db SureSQLDB) ([]orm.DBRecords, error) { return db.SelectManySQLParameterized(paramSQLs) })
+}
+
+func (d RoutedDB) SelectOnlyOneSQLParameterized(paramSQL orm.ParametereizedSQL) (orm.DBRecord, error) {
+	return routeRead(d, func(db SureSQLDB) (orm.DBRecord, error) { return db.SelectOnlyOneSQLParameterized(paramSQL) })
+}
+
+func (d RoutedDB) ExecOneSQL(sql string) orm.BasicSQLResult {
+	return routeWriteResult(d, func(db SureSQLDB) orm.BasicSQLResult { return db.ExecOneSQL(sql) })
+}
+
+func (d RoutedDB) ExecOneSQLParameterized(paramSQL orm.ParametereizedSQL) orm.BasicSQLResult {
+	return routeWriteResult(d, func(db SureSQLDB) orm.BasicSQLResult { return db.ExecOneSQLParameterized(paramSQL) })
+}
+
+func (d RoutedDB) ExecManySQL(sqls []string) ([]orm.BasicSQLResult, error) {
+	return routeWrite(d, func(db SureSQLDB) ([]orm.BasicSQLResult, error) { return db.ExecManySQL(sqls) })
+}
+
+func (d RoutedDB) ExecManySQLParameterized(paramSQLs []orm.ParametereizedSQL) ([]orm.BasicSQLResult, error) {
+	return routeWrite(d, func(db SureSQLDB) ([]orm.BasicSQLResult, error) { return db.ExecManySQLParameterized(paramSQLs) })
+}
+
+func (d RoutedDB) InsertOneDBRecord(record orm.DBRecord, queue bool) orm.BasicSQLResult {
+	return routeWriteResult(d, func(db SureSQLDB) orm.BasicSQLResult { return db.InsertOneDBRecord(record, queue) })
+}
+
+func (d RoutedDB) InsertManyDBRecords(records []orm.DBRecord, queue bool) ([]orm.BasicSQLResult, error) {
+	return routeWrite(d, func(db SureSQLDB) ([]orm.BasicSQLResult, error) { return db.InsertManyDBRecords(records, queue) })
+}
+
+func (d RoutedDB) InsertManyDBRecordsSameTable(records []orm.DBRecord, queue bool) ([]orm.BasicSQLResult, error) {
+	return routeWrite(d, func(db SureSQLDB) ([]orm.BasicSQLResult, error) {
+		return db.InsertManyDBRecordsSameTable(records, queue)
+	})
+}
+
+func (d RoutedDB) InsertOneTableStruct(obj orm.TableStruct, queue bool) orm.BasicSQLResult {
+	return routeWriteResult(d, func(db SureSQLDB) orm.BasicSQLResult { return db.InsertOneTableStruct(obj, queue) })
+}
+
+func (d RoutedDB) InsertManyTableStructs(objs []orm.TableStruct, queue bool) ([]orm.BasicSQLResult, error) {
+	return routeWrite(d, func(db SureSQLDB) ([]orm.BasicSQLResult, error) { return db.InsertManyTableStructs(objs, queue) })
+}
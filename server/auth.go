@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/medatechnology/suresql"
@@ -14,6 +15,7 @@ import (
 	"github.com/medatechnology/goutil/medaerror"
 	"github.com/medatechnology/goutil/medattlmap"
 	"github.com/medatechnology/goutil/object"
+	"github.com/medatechnology/goutil/simplelog"
 )
 
 // Constant for auth related like token settings
@@ -31,41 +33,65 @@ var (
 	// Instead of Redis, we use ttlmap is lighter
 	// TokenMap        *medattlmap.TTLMap // For access tokens
 	// RefreshTokenMap *medattlmap.TTLMap // For refresh tokens
-	TokenStore TokenStoreStruct
+	TokenStore *TokenStoreStruct
 )
 
 // Mini Redis like Key-Value storage based on MedaTTLMap
 type TokenStoreStruct struct {
 	TokenMap        *medattlmap.TTLMap // For access tokens
 	RefreshTokenMap *medattlmap.TTLMap // For refresh tokens
+	// guards the maps swap when resized, the maps themselves are safe for concurrent use
+	mu sync.RWMutex
 }
 
-// InitTokenMaps initializes the token maps with default TTLs
+// InitTokenMaps initializes the token maps with the TTLs of the token settings
 func InitTokenMaps() {
-	// Initialize with default expiration times
-	TokenStore = NewTokenStore(suresql.DEFAULT_TOKEN_EXPIRES_MINUTES, suresql.DEFAULT_REFRESH_EXPIRES_MINUTES)
+	conf := suresql.Node().Config
+	TokenStore = NewTokenStore(conf.TokenExp, conf.RefreshExp, conf.TTLTicker)
 }
 
-func NewTokenStore(exp, rexp time.Duration) TokenStoreStruct {
-	return TokenStoreStruct{
-		TokenMap:        medattlmap.NewTTLMap(exp, suresql.DEFAULT_TTL_TICKER_MINUTES),
-		RefreshTokenMap: medattlmap.NewTTLMap(rexp, suresql.DEFAULT_TTL_TICKER_MINUTES),
+// Zero values are the defaults, each token is saved with its own expiry anyway
+func NewTokenStore(exp, rexp, tick time.Duration) *TokenStoreStruct {
+	if exp <= 0 {
+		exp = suresql.DEFAULT_TOKEN_EXPIRES_MINUTES
 	}
+	if rexp <= 0 {
+		rexp = suresql.DEFAULT_REFRESH_EXPIRES_MINUTES
+	}
+	if tick <= 0 {
+		tick = suresql.DEFAULT_TTL_TICKER_MINUTES
+	}
+	return &TokenStoreStruct{
+		TokenMap:        medattlmap.NewTTLMap(exp, tick),
+		RefreshTokenMap: medattlmap.NewTTLMap(rexp, tick),
+	}
+}
+
+func (t *TokenStoreStruct) maps() (*medattlmap.TTLMap, *medattlmap.TTLMap) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.TokenMap, t.RefreshTokenMap
 }
 
-func (t TokenStoreStruct) GetAll() (map[string]interface{}, map[string]interface{}) {
-	return t.TokenMap.Map(), t.RefreshTokenMap.Map()
+func (t *TokenStoreStruct) GetAll() (map[string]interface{}, map[string]interface{}) {
+	tokens, refresh := t.maps()
+	return tokens.Map(), refresh.Map()
 }
 
 // Check if tokenExist, if it is, return the value of the TokenMap[token] - which is interface{} type
-func (t TokenStoreStruct) SaveToken(token suresql.TokenTable) {
-	t.TokenMap.Put(token.Token, 0, token)
-	t.RefreshTokenMap.Put(token.Refresh, 0, token)
+func (t *TokenStoreStruct) SaveToken(token suresql.TokenTable) {
+	// Read lock for the whole save, so Resize does not copy the maps in between
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	// expiry is per token, it can be overwritten for the role or user
+	t.TokenMap.Put(token.Token, time.Until(token.TokenExpiresAt), token)
+	t.RefreshTokenMap.Put(token.Refresh, time.Until(token.RefreshExpiresAt), token)
 }
 
 // Check if tokenExist, if it is, return the value of the TokenMap[token] - which is interface{} type
-func (t TokenStoreStruct) TokenExist(token string) (*suresql.TokenTable, bool) {
-	val, ok := t.TokenMap.Get(token)
+func (t *TokenStoreStruct) TokenExist(token string) (*suresql.TokenTable, bool) {
+	tokens, _ := t.maps()
+	val, ok := tokens.Get(token)
 	// fmt.Println("All TokenMap:", t.TokenMap.Map())
 	if !ok {
 		return nil, false
@@ -76,8 +102,9 @@ func (t TokenStoreStruct) TokenExist(token string) (*suresql.TokenTable, bool) {
 }
 
 // Check if tokenExist, if it is, return the value of the TokenMap[token] - which is interface{} type
-func (t TokenStoreStruct) RefreshTokenExist(token string) (*suresql.TokenTable, bool) {
-	val, ok := t.RefreshTokenMap.Get(token)
+func (t *TokenStoreStruct) RefreshTokenExist(token string) (*suresql.TokenTable, bool) {
+	_, refresh := t.maps()
+	val, ok := refresh.Get(token)
 	if !ok {
 		return nil, false
 	}
@@ -86,6 +113,55 @@ func (t TokenStoreStruct) RefreshTokenExist(token string) (*suresql.TokenTable,
 	return &tok, true
 }
 
+func (t *TokenStoreStruct) DeleteRefreshToken(token string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.RefreshTokenMap.Delete(token)
+}
+
+// Resize replaces the maps with new ones of the new TTLs. Tokens that are already issued keep their
+// expiry (TokenExpiresAt and RefreshExpiresAt), only the new tokens get the new TTLs.
+func (t *TokenStoreStruct) Resize(exp, rexp, tick time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tokens := medattlmap.NewTTLMap(exp, tick)
+	refresh := medattlmap.NewTTLMap(rexp, tick)
+	for key, tok := range tokensOf(t.TokenMap) {
+		if remaining := time.Until(tok.TokenExpiresAt); remaining > 0 {
+			tokens.Put(key, remaining, tok)
+		}
+	}
+	for key, tok := range tokensOf(t.RefreshTokenMap) {
+		if remaining := time.Until(tok.RefreshExpiresAt); remaining > 0 {
+			refresh.Put(key, remaining, tok)
+		}
+	}
+	t.TokenMap.Stop()
+	t.RefreshTokenMap.Stop()
+	t.TokenMap, t.RefreshTokenMap = tokens, refresh
+}
+
+// Refresh expiry of the sessions by access token, the pooled DB connections live as long as the session
+func (t *TokenStoreStruct) SessionExpiries() map[string]time.Time {
+	_, refresh := t.maps()
+	expires := make(map[string]time.Time)
+	for _, tok := range tokensOf(refresh) {
+		expires[tok.Token] = tok.RefreshExpiresAt
+	}
+	return expires
+}
+
+// Called after settings are reloaded, the token maps and the DB pool get the new TTLs if they are changed
+func ResizeSessions(before suresql.ConfigTable) {
+	conf := suresql.Node().Config
+	if conf.TokenExp == before.TokenExp && conf.RefreshExp == before.RefreshExp && conf.TTLTicker == before.TTLTicker {
+		return
+	}
+	TokenStore.Resize(conf.TokenExp, conf.RefreshExp, conf.TTLTicker)
+	suresql.CurrentNode.ResizeDBConnections(conf.RefreshExp, conf.TTLTicker, TokenStore.SessionExpiries())
+	simplelog.LogFormat("token maps resized, token:%s refresh:%s ticker:%s", conf.TokenExp, conf.RefreshExp, conf.TTLTicker)
+}
+
 // This read from default _user table which is internal suresql table for username
 func userNameExist(username string) (UserTable, error) {
 	// Find user in database
@@ -107,7 +183,7 @@ func userNameExist(username string) (UserTable, error) {
 }
 
 func passwordMatch(user UserTable, pass string) error {
-	encr, err := encryption.HashPin(pass, suresql.CurrentNode.Config.APIKey, suresql.CurrentNode.Config.ClientID)
+	encr, err := encryption.HashPin(pass, suresql.Node().Config.APIKey, suresql.Node().Config.ClientID)
 	if err != nil {
 		return err
 	}
@@ -126,8 +202,11 @@ func createNewTokenResponse(user UserTable) suresql.TokenTable {
 	token.Refresh = encryption.NewRandomTokenIterate(TOKEN_LENGTH_MULTIPLIER)
 	token.UserID = fmt.Sprintf("%d", user.ID)
 	token.UserName = user.Username
-	token.TokenExpiresAt = time.Now().Add(suresql.DEFAULT_TOKEN_EXPIRES_MINUTES)
-	token.RefreshExpiresAt = time.Now().Add(suresql.DEFAULT_REFRESH_EXPIRES_MINUTES)
+	token.RoleName = user.RoleName
+	tokenExp, refreshExp := suresql.Node().TokenLifetime(user.Username, user.RoleName)
+	now := time.Now()
+	token.TokenExpiresAt = now.Add(tokenExp)
+	token.RefreshExpiresAt = now.Add(refreshExp)
 
 	// Store tokens in TTL maps with appropriate expiration times
 	// TokenMap.Put(token, DEFAULT_TOKEN_EXPIRATION, user.Username)
@@ -179,3 +258,74 @@ func DecodeToken(tokenstring string, config *suresql.SureSQLDBMSConfig) (string,
 	config.Token = tokenMap[TOKEN_STRING]
 	return config.Token, nil
 }
+
+// Sessions returns the active access tokens, only of username if it is not empty
+func (t *TokenStoreStruct) Sessions(username string) []suresql.TokenTable {
+	tokens, _ := t.maps()
+	var list []suresql.TokenTable
+	for _, tok := range tokensOf(tokens) {
+		if username != "" && tok.UserName != username {
+			continue
+		}
+		list = append(list, tok)
+	}
+	return list
+}
+
+// RevokeUser removes all access and refresh tokens of the user, and the pooled DB connections
+// that belong to the access tokens. Returns the number of sessions removed.
+func (t *TokenStoreStruct) RevokeUser(username string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	revoked := 0
+	for key, tok := range tokensOf(t.TokenMap) {
+		if tok.UserName == username {
+			t.TokenMap.Delete(key)
+			suresql.CurrentNode.RemoveDBConnection(key)
+			revoked++
+		}
+	}
+	// Refresh token can outlive the access token, remove it as well so the session cannot be refreshed
+	for key, tok := range tokensOf(t.RefreshTokenMap) {
+		if tok.UserName == username {
+			t.RefreshTokenMap.Delete(key)
+			if _, exist := t.TokenMap.Get(tok.Token); !exist {
+				suresql.CurrentNode.RemoveDBConnection(tok.Token)
+			}
+		}
+	}
+	return revoked
+}
+
+// RevokeAll removes all access and refresh tokens, returns the number of access tokens removed
+func (t *TokenStoreStruct) RevokeAll() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	revoked := 0
+	for key := range t.TokenMap.Map() {
+		t.TokenMap.Delete(key)
+		revoked++
+	}
+	for key := range t.RefreshTokenMap.Map() {
+		t.RefreshTokenMap.Delete(key)
+	}
+	return revoked
+}
+
+// Called after a restore, the pooled connections are closed by the restore itself
+func RevokeAllSessions() {
+	simplelog.LogFormat("restore: %d sessions revoked", TokenStore.RevokeAll())
+}
+
+// The values of TTLMap.Map() are the map items (with expiration), the tokens are read with Get
+func tokensOf(m *medattlmap.TTLMap) map[string]suresql.TokenTable {
+	tokens := make(map[string]suresql.TokenTable)
+	for key := range m.Map() {
+		if val, ok := m.Get(key); ok {
+			if tok, ok := val.(suresql.TokenTable); ok {
+				tokens[key] = tok
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+const (
+	// Caller identity when follower forwards the write to the leader's internal API
+	FORWARDED_USER_HEADER = "X-SureSQL-User"
+	FORWARDED_ROLE_HEADER = "X-SureSQL-Role"
+	FORWARDED_NODE_HEADER = "X-SureSQL-Node"
+	FORWARDED_STRING      = "forwarded"
+
+	FORWARD_SQL_PATH    = "/forward/sql"
+	FORWARD_INSERT_PATH = "/forward/insert"
+)
+
+// Returned for redirect mode so client can re-send to the leader
+type ForwardResponse struct {
+	Leader string `json:"leader"`
+	URL    string `json:"url"`
+}
+
+// Only for write end-points (/sql and /insert). If this node is a follower, the write is sent to the leader
+// (proxy) or the client is told to go to the leader (redirect), depends on routing.write_forward setting.
+// Needs TokenValidationFromTTL before this.
+func WriteForwarding(forwardPath string) simplehttp.MiddlewareFunc {
+	return func(next simplehttp.HandlerFunc) simplehttp.HandlerFunc {
+		return func(ctx simplehttp.Context) error {
+			if suresql.Node().WriteForward == suresql.WRITE_FORWARD_OFF {
+				return next(ctx)
+			}
+			leader, ok := suresql.Node().LeaderURL()
+			if !ok {
+				return next(ctx)
+			}
+
+			state := NewMiddlewareState(ctx, "write forward")
+			tok, ok := ctx.Get(TOKEN_TABLE_STRING).(*suresql.TokenTable)
+			if !ok || tok == nil {
+				return state.SetError("Authentication token required", nil, http.StatusUnauthorized).LogAndResponse("no token", nil, true)
+			}
+			state.User = tok.UserName
+
+			if suresql.Node().WriteForward == suresql.WRITE_FORWARD_REDIRECT {
+				url := leader + ctx.GetPath()
+				ctx.SetResponseHeader("Location", url)
+				return state.SetError("Write must be sent to the leader", nil, http.StatusTemporaryRedirect).
+					LogAndResponse("redirect write to leader "+leader, ForwardResponse{Leader: leader, URL: url}, true)
+			}
+
+			// Proxy, the leader trusts the caller identity because we authenticate with internal credentials
+			req, err := http.NewRequest(http.MethodPost, leader+DEFAULT_INTERNAL_API+forwardPath, bytes.NewReader(ctx.GetBody()))
+			if err != nil {
+				return state.SetError("Failed to forward write to the leader", err, http.StatusInternalServerError).LogAndResponse("cannot create forward request", nil, true)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(FORWARDED_USER_HEADER, tok.UserName)
+			req.Header.Set(FORWARDED_ROLE_HEADER, tok.RoleName)
+			req.Header.Set(FORWARDED_NODE_HEADER, fmt.Sprintf("%d", suresql.Node().Config.NodeNumber))
+			req.SetBasicAuth(suresql.CurrentNode.InternalConfig.Username, suresql.CurrentNode.InternalConfig.Password)
+
+			client := http.Client{Timeout: suresql.Node().Config.HttpTimeout}
+			resp, err := client.Do(req)
+			if err != nil {
+				return state.SetError("Leader is not reachable", err, http.StatusBadGateway).LogAndResponse("failed to forward write to leader "+leader, nil, true)
+			}
+			// Read it all here, the stream is read by fiber after the handler returns and the body is closed by then
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return state.SetError("Leader response cannot be read", err, http.StatusBadGateway).LogAndResponse("failed to read response of leader "+leader, nil, true)
+			}
+
+			state.OnlyLog(fmt.Sprintf("write forwarded to leader %s, status %d", leader, resp.StatusCode), nil, false)
+			return ctx.Stream(resp.StatusCode, resp.Header.Get("Content-Type"), bytes.NewReader(body))
+		}
+	}
+}
+
+// Leader side of the proxy (internal API, basic auth). Takes the caller identity from the headers set by
+// the follower and put it as token in the context, so the write handlers and logs see the original user.
+func ForwardedIdentity() simplehttp.MiddlewareFunc {
+	return func(next simplehttp.HandlerFunc) simplehttp.HandlerFunc {
+		return func(ctx simplehttp.Context) error {
+			state := NewMiddlewareState(ctx, "forwarded")
+			user := ctx.GetHeader(FORWARDED_USER_HEADER)
+			if user == "" {
+				return state.SetError("Forwarded user is required", nil, http.StatusBadRequest).LogAndResponse("no forwarded user header", nil, true)
+			}
+			ctx.Set(TOKEN_TABLE_STRING, &suresql.TokenTable{
+				UserName: user,
+				RoleName: ctx.GetHeader(FORWARDED_ROLE_HEADER),
+			})
+			ctx.Set(FORWARDED_STRING, ctx.GetHeader(FORWARDED_NODE_HEADER))
+			return next(ctx)
+		}
+	}
+}
+
+// Forwarded writes do not have the user's connection in this node pool, use the internal connection.
+func getUserConnection(ctx simplehttp.Context, state HandlerState) (suresql.SureSQLDB, error) {
+	if _, ok := ctx.Get(FORWARDED_STRING).(string); ok {
+		return suresql.CurrentNode.InternalConnection, nil
+	}
+	return suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
+}
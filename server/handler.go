@@ -73,6 +73,13 @@ func CreateServer(cnode suresql.SureSQLNode) simplehttp.Server {
 	InitTokenMaps()
 	metrics.StopTimeItPrint(el, "Done")
 
+	// Scheduled backups are logged like the handlers
+	suresql.BackupHook = LogScheduledBackup
+	// Token maps follow the token settings when they are reloaded
+	suresql.ReloadHook = ResizeSessions
+	// Users of the restored database can be different, everyone connects again
+	suresql.RestoreHook = RevokeAllSessions
+
 	el = metrics.StartTimeIt("Registring endpoints ...", 0)
 	RegisterRoutes(server)
 	metrics.StopTimeItPrint(el, "Done")
@@ -107,7 +114,7 @@ func RegisterRoutes(server simplehttp.Server) {
 
 	db := server.Group("/db")
 	// All API need API_KEY, later all queries need TOKEN
-	db.Use(MiddlewareAPIKeyHeader())
+	db.Use(MiddlewareDBMSConnected(), MiddlewareAPIKeyHeader())
 	{
 		db.POST("/connect", HandleConnect)
 		db.POST("/refresh", HandleRefresh)
@@ -118,14 +125,21 @@ func RegisterRoutes(server simplehttp.Server) {
 	}
 
 	api := db.Group("/api")
-	api.Use(MiddlwareTokenCheck())
+	// Groups do not share the middleware, the DBMS check is needed here as well
+	api.Use(MiddlewareDBMSConnected(), MiddlwareTokenCheck())
 	{
 		api.GET("/status", HandleDBStatus)
 		api.GET("/getschema", HandleGetSchema) // this is actually not working, because it should be used only for SaaS
-		api.POST("/sql", HandleSQLExecution)
+		api.POST("/sql", RawSQLAccessCheck()(WriteForwarding(FORWARD_SQL_PATH)(HandleSQLExecution)))
 		api.POST("/query", HandleQuery)
-		api.POST("/querysql", HandleSQLQuery)
-		api.POST("/insert", HandleInsert)
+		api.POST("/querysql", RawSQLAccessCheck()(HandleSQLQuery))
+		api.POST("/insert", WriteForwarding(FORWARD_INSERT_PATH)(HandleInsert))
+		api.POST("/named/:name", HandleNamedQuery)
+		// Schema migrations of the client app, these are raw SQL as well
+		api.GET("/migrations", HandleListAppMigrations)
+		api.POST("/migrations", RawSQLAccessCheck()(HandleUploadAppMigration))
+		api.POST("/migrations/up", RawSQLAccessCheck()(HandleAppMigrateUp))
+		api.POST("/migrations/down", RawSQLAccessCheck()(HandleAppMigrateDown))
 	}
 
 	// simplelog.LogThis("Routes registered successfully")
@@ -173,7 +187,7 @@ func HandleConnect(ctx simplehttp.Context) error {
 
 	// Add to connection pool if enabled
 	if suresql.CurrentNode.IsPoolAvailable() {
-		suresql.CurrentNode.DBConnections.Put(tokenResponse.Token, 0, newDB)
+		suresql.CurrentNode.AddDBConnection(tokenResponse.Token, newDB, time.Until(tokenResponse.RefreshExpiresAt))
 		// state.OnlyLog(fmt.Sprintf("Added new connection to pool, current size: %d/%d", suresql.suresql.CurrentNode.DBConnections.Len(), suresql.CurrentNode.MaxPool), nil, true)
 	} else {
 		err := errors.New("db pool quota exceeded")
@@ -208,11 +222,11 @@ func HandleRefresh(ctx simplehttp.Context) error {
 
 	state.User = tokmap.UserName
 	// Generate new tokens using NewRandomTokenIterate with TOKEN_LENGTH_MULTIPLIER
-	tokenResponse := createNewTokenResponse(UserTable{Username: tokmap.UserName, ID: object.Int(tokmap.UserID, false)})
+	tokenResponse := createNewTokenResponse(UserTable{Username: tokmap.UserName, ID: object.Int(tokmap.UserID, false), RoleName: tokmap.RoleName})
 	// Remove old refresh token
-	TokenStore.RefreshTokenMap.Delete(refreshReq.Refresh)
+	TokenStore.DeleteRefreshToken(refreshReq.Refresh)
 	// Rename the DBConnection to new token from the old token
-	suresql.CurrentNode.RenameDBConnection(tokmap.Token, tokenResponse.Token)
+	suresql.CurrentNode.RenameDBConnection(tokmap.Token, tokenResponse.Token, time.Until(tokenResponse.RefreshExpiresAt))
 
 	return state.SetSuccess("Token refreshed successfully", tokenResponse).
 		LogAndResponse("refreshede tokens for user: "+tokmap.UserName, nil, true)
@@ -237,25 +251,31 @@ func HandleDBStatus(ctx simplehttp.Context) error {
 	}
 
 	// Get database status
-	// TODO: at this moment the status from RQLite only get the leader and peers as string
-	// -     this should change to getting the real status from rqlite, but go-rqlite is limited!
-	status, err := suresql.GetStatusInternal(userDB, suresql.NODE_MODE)
+	// The peers from the driver status are only strings, the real cluster membership is from DiscoverCluster below
+	_, err = suresql.GetStatusInternal(userDB, suresql.NODE_MODE)
 	if err != nil {
 		return state.SetError("Cannot get DB status", err, http.StatusInternalServerError).LogAndResponse("cannot get DB status", nil, true)
 	}
-	msg := "Status peers vs config mismatched"
-	// TODO: check which one is valid, from the RQLIte status vs SureSQLNode.Config which we put to status
-	if len(suresql.CurrentNode.Status.Peers) == len(status.Peers) {
-		msg = "Status peers vs config matched"
+	// Compare the real DBMS cluster (rqlite /nodes and /status) with the nodes settings
+	drift := suresql.Node().DiscoverCluster()
+	msg := "Status peers vs config matched"
+	if !drift.InSync {
+		msg = "Status peers vs config mismatched: " + drift.String()
+	}
+	if backup := suresql.Node().BackupStatus(); backup != nil && backup.LastError != "" {
+		msg += "; scheduled backup failed: " + backup.LastError
 	}
 
 	// NOTE: should we return the uptime of the DBMS behind SureSQL or just the uptime of SureSQL service server instead?
 	// Now we are returning the server uptime, not the DBMS. If want the DBMS then set this to: status.Uptime.
-	suresql.CurrentNode.Status.Uptime = time.Since(suresql.ServerStartTime) // this is refreshed when Status handler is called
+	node := suresql.Node()
+	status := node.Status
+	status.Uptime = time.Since(suresql.ServerStartTime) // this is refreshed when Status handler is called
 
-	// return state.SetSuccess(msg, suresql.CurrentNode.Status).LogAndResponse(fmt.Sprintf("user: %s, db status: %s", state.User, status), suresql.CurrentNode.Settings, true)
 	// Decided not to log the data for success
-	return state.SetSuccess(msg, suresql.CurrentNode.Status).LogAndResponse(fmt.Sprintf("client user: %s", state.User), nil, true)
+	response := node.StatusWithHealth(status)
+	response.Cluster = &drift
+	return state.SetSuccess(msg, response).LogAndResponse(fmt.Sprintf("client user: %s", state.User), nil, true)
 	// return state.SetSuccess(msg, map[string]interface{}{
 	// 	"status":       suresql.CurrentNode.Status,
 	// 	"node_info":    suresql.CurrentNode.Settings,
@@ -269,3 +289,15 @@ func HandleDBStatus(ctx simplehttp.Context) error {
 	// 	"connected_as": token.UserName,
 	// })
 }
+
+// Check the consistency from the request body, returns the http status to respond with if it is not valid
+// or not allowed for the user's role.
+func checkConsistency(state HandlerState, level string) (int, error) {
+	if err := suresql.ValidateConsistency(level); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if state.Token != nil && !suresql.Node().IsConsistencyAllowed(state.Token.RoleName, level) {
+		return http.StatusForbidden, errors.New("consistency " + level + " is not allowed for role " + state.Token.RoleName)
+	}
+	return http.StatusOK, nil
+}
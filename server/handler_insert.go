@@ -32,11 +32,12 @@ func HandleInsert(ctx simplehttp.Context) error {
 		return state.SetError("No records provided", nil, http.StatusBadRequest).LogAndResponse("no records in request body", nil, true)
 	}
 
-	// Find the user's database connection from TTL map
-	userDB, err := suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
+	// Find the user's database connection from TTL map (or internal connection if forwarded from follower)
+	userDB, err := getUserConnection(ctx, state)
 	if err != nil {
 		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
 	}
+	userDB = suresql.Node().RoutedConnection(userDB)
 
 	// Prepare response
 	response := suresql.SQLResponse{
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// AppMigrateRequest applies (up) or rolls back (down) the migrations of the app
+type AppMigrateRequest struct {
+	App    string `json:"app"`
+	Steps  int    `json:"steps,omitempty"`   // only for down, default 1
+	DryRun bool   `json:"dry_run,omitempty"` // return the migrations that would run, nothing is executed
+}
+
+// AppMigrateResponse has the migrations that were applied or rolled back
+type AppMigrateResponse struct {
+	App        string              `json:"app"`
+	Direction  string              `json:"direction"`
+	DryRun     bool                `json:"dry_run,omitempty"`
+	Migrations []suresql.Migration `json:"migrations"`
+}
+
+// The caller has to be the owner of the app migrations (see suresql.AuthorizeAppOwner), claim makes the caller
+// the owner of the app without one.
+func authorizeAppOwner(state *HandlerState, app string, claim, byRole bool) (int, error) {
+	var username, role string
+	if state.Token != nil {
+		username, role = state.Token.UserName, state.Token.RoleName
+	}
+	err := suresql.Node().AuthorizeAppOwner(app, username, role, claim, byRole)
+	if errors.Is(err, suresql.ErrAppNotOwner) {
+		return http.StatusForbidden, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// The migrations run on the caller's connection, routed to the leader like the other writes
+func appMigrationConnection(ctx simplehttp.Context, state HandlerState) (suresql.SureSQLDB, error) {
+	userDB, err := getUserConnection(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	return suresql.Node().RoutedConnection(userDB), nil
+}
+
+// HandleListAppMigrations returns the uploaded migrations of the app (?app=) and if they are applied
+func HandleListAppMigrations(ctx simplehttp.Context) error {
+	state := NewHandlerTokenState(ctx, "list_app_migrations", suresql.APP_MIGRATION_TABLE)
+
+	app := ctx.GetQueryParam("app")
+	if app == "" {
+		return state.SetError("app is required", nil, http.StatusBadRequest).LogAndResponse("missing app", nil, true)
+	}
+	if code, err := authorizeAppOwner(&state, app, false, false); err != nil {
+		return state.SetError("Not allowed: "+err.Error(), err, code).LogAndResponse("app:"+app+" owner check failed", nil, true)
+	}
+	userDB, err := appMigrationConnection(ctx, state)
+	if err != nil {
+		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
+	}
+	list, err := suresql.Node().AppMigrations(app)
+	if err != nil {
+		return state.SetError("Failed to get migrations", err, http.StatusInternalServerError).LogAndResponse("failed to read migration scripts", nil, true)
+	}
+	status, err := suresql.Node().AppMigrator(app, userDB).Status(list)
+	if err != nil {
+		return state.SetError("Failed to get migrations", err, http.StatusInternalServerError).LogAndResponse("failed to read applied migrations", nil, true)
+	}
+	return state.SetSuccess(fmt.Sprintf("Migrations of %s: %d", app, len(status)), status).LogAndResponse(fmt.Sprintf("app:%s count:%d", app, len(status)), nil, true)
+}
+
+// HandleUploadAppMigration stores the up/down script of one version, it is applied with /migrations/up. The
+// first upload of the app makes the caller the owner, or the caller's role with ?owner=role
+func HandleUploadAppMigration(ctx simplehttp.Context) error {
+	state := NewHandlerTokenState(ctx, "upload_app_migration", suresql.AppMigrationTable{}.TableName())
+
+	var script suresql.AppMigrationTable
+	if err := ctx.BindJSON(&script); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+	if err := script.Validate(); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid migration", nil, true)
+	}
+	byRole := ctx.GetQueryParam("owner") == "role"
+	if code, err := authorizeAppOwner(&state, script.App, true, byRole); err != nil {
+		return state.SetError("Not allowed: "+err.Error(), err, code).LogAndResponse("app:"+script.App+" owner check failed", nil, true)
+	}
+	if err := suresql.CurrentNode.SaveAppMigration(script); err != nil {
+		return state.SetError("Failed to save migration: "+err.Error(), err, http.StatusConflict).LogAndResponse("failed to save migration", nil, true)
+	}
+	script.UpSQL, script.DownSQL = "", ""
+	return state.SetSuccess("Migration saved successfully", script).LogAndResponse(fmt.Sprintf("app:%s version:%s saved", script.App, script.Version), nil, true)
+}
+
+// HandleAppMigrateUp applies the migrations of the app that are not yet applied
+func HandleAppMigrateUp(ctx simplehttp.Context) error {
+	return handleAppMigrate(ctx, suresql.MIGRATION_UP)
+}
+
+// HandleAppMigrateDown rolls back the last applied migrations of the app with their down script
+func HandleAppMigrateDown(ctx simplehttp.Context) error {
+	return handleAppMigrate(ctx, suresql.MIGRATION_DOWN)
+}
+
+func handleAppMigrate(ctx simplehttp.Context, direction string) error {
+	state := NewHandlerTokenState(ctx, "app_migrate_"+direction, suresql.APP_MIGRATION_TABLE)
+
+	var req AppMigrateRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+	if req.App == "" {
+		return state.SetError("app is required", nil, http.StatusBadRequest).LogAndResponse("missing app", nil, true)
+	}
+	if req.Steps <= 0 {
+		req.Steps = 1
+	}
+	if code, err := authorizeAppOwner(&state, req.App, !req.DryRun, false); err != nil {
+		return state.SetError("Not allowed: "+err.Error(), err, code).LogAndResponse("app:"+req.App+" owner check failed", nil, true)
+	}
+	userDB, err := appMigrationConnection(ctx, state)
+	if err != nil {
+		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
+	}
+
+	list, err := suresql.Node().AppMigrations(req.App)
+	if err != nil {
+		return state.SetError("Failed to get migrations", err, http.StatusInternalServerError).LogAndResponse("failed to read migration scripts", nil, true)
+	}
+	migrator := suresql.Node().AppMigrator(req.App, userDB)
+	response := AppMigrateResponse{App: req.App, Direction: direction, DryRun: req.DryRun}
+
+	if req.DryRun {
+		response.Migrations, err = migrator.Plan(list, direction, req.Steps)
+	} else if direction == suresql.MIGRATION_DOWN {
+		response.Migrations, err = migrator.Down(list, req.Steps)
+	} else {
+		response.Migrations, err = migrator.Up(list)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var merr suresql.MigrationError
+		if errors.As(err, &merr) || errors.Is(err, suresql.ErrMigrationDrift) {
+			status = http.StatusUnprocessableEntity
+		}
+		return state.SetError("Migration failed: "+err.Error(), err, status).LogAndResponse(fmt.Sprintf("app:%s %s failed after %d migrations", req.App, direction, len(response.Migrations)), nil, true)
+	}
+	return state.SetSuccess(fmt.Sprintf("Migrations %s: %d", direction, len(response.Migrations)), response).LogAndResponse(fmt.Sprintf("app:%s %s count:%d", req.App, direction, len(response.Migrations)), nil, true)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/medatechnology/suresql"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/object"
+	"github.com/medatechnology/simplehttp"
+)
+
+// HandleNamedQuery executes a stored query from the registry (_named_queries) by its name.
+// Client only sends the values for the placeholders, never the SQL itself.
+func HandleNamedQuery(ctx simplehttp.Context) error {
+	state := NewHandlerTokenState(ctx, "/named/", suresql.NamedQueryTable{}.TableName())
+
+	// Get username from context (set by TokenValidationFromTTL)
+	if state.Token == nil {
+		return state.SetError("Cannot retrieve token from context", nil, http.StatusUnauthorized).LogAndResponse("cannot retrieve token from context, should not happen because of middleware", nil, true)
+	}
+
+	// Name is the last part of the path: /db/api/named/{name}
+	name := path.Base(ctx.GetPath())
+	if name == "" || name == "named" || name == "/" {
+		return state.SetError("Named query is required", nil, http.StatusBadRequest).LogAndResponse("no name in path", nil, true)
+	}
+	state.Label += name
+
+	// Parse request body, body is optional if the query has no placeholders
+	var namedReq suresql.NamedQueryRequest
+	if len(ctx.GetBody()) > 0 {
+		if err := ctx.BindJSON(&namedReq); err != nil {
+			return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("Failed to parse request body", nil, true)
+		}
+	}
+
+	named, err := namedQueryExist(name)
+	if err != nil {
+		return namedQueryError(&state, name, err)
+	}
+
+	// Find the user's database connection from TTL map
+	userDB, err := suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
+	if err != nil {
+		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
+	}
+	userDB = suresql.Node().RoutedConnection(userDB)
+
+	paramSQL := named.ToParameterized(namedReq.Values)
+
+	// Exec type: insert, update, delete
+	if !named.IsQuery() {
+		response := suresql.SQLResponse{
+			Results: []orm.BasicSQLResult{},
+		}
+		result := userDB.ExecOneSQLParameterized(paramSQL)
+		response.Results = append(response.Results, result)
+		if result.Error != nil {
+			return state.SetError("Failed to execute named query", result.Error, http.StatusInternalServerError).LogAndResponse("failed to execute named query "+name, paramSQL, true)
+		}
+		response.RowsAffected = result.RowsAffected
+		response.ExecutionTime = state.SaveStopTimer()
+		return state.SetSuccess("Named query executed successfully", response).LogAndResponse("named query executed successfully", response, true)
+	}
+
+	// Query type: select, the caps are applied while the DBMS response is read
+	limiter := suresql.Node().NewResponseLimiter()
+	results, err := runQuery(userDB, []orm.ParametereizedSQL{paramSQL}, namedReq.SingleRow, false, limiter)
+	if err != nil {
+		return state.SetError("Failed to execute named query", err, http.StatusInternalServerError).LogAndResponse("failed to execute named query "+name, paramSQL, true)
+	}
+	response := suresql.QueryResponse{
+		Records: []orm.DBRecord{},
+	}
+	if len(results) > 0 {
+		response.Records = results[0].Records
+		response.Count = len(results[0].Records)
+		response.Truncated = results[0].Truncated
+	}
+	if response.Count == 0 {
+		state.LogMessage = "executed with no results"
+	}
+
+	response.ExecutionTime = state.SaveStopTimer()
+	return state.SetSuccess("Named query executed successfully", response).LogAndResponse("named query executed successfully", response, true)
+}
+
+// Not found is 404, any other error means the registry cannot be read (ie: DBMS is down)
+func namedQueryError(state *HandlerState, name string, err error) error {
+	if err == orm.ErrSQLNoRows {
+		return state.SetError("Named query "+name+" not found", err, http.StatusNotFound).LogAndResponse("named query "+name+" not found", nil, true)
+	}
+	return state.SetError("Cannot read named query "+name, err, http.StatusInternalServerError).LogAndResponse("cannot read named query "+name, nil, true)
+}
+
+// This read from _named_queries table which is internal suresql table for the query registry
+func namedQueryExist(name string) (suresql.NamedQueryTable, error) {
+	condition := orm.Condition{
+		Field:    "name",
+		Operator: "=",
+		Value:    name,
+	}
+
+	var named suresql.NamedQueryTable
+	record, err := suresql.CurrentNode.InternalConnection.SelectOneWithCondition(named.TableName(), &condition)
+	if err != nil {
+		return named, err
+	}
+
+	named = object.MapToStructSlowDB[suresql.NamedQueryTable](record.Data)
+	return named, nil
+}
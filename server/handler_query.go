@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/medatechnology/suresql"
 
@@ -34,95 +35,77 @@ func HandleQuery(ctx simplehttp.Context) error {
 	if queryReq.Table == "" {
 		return state.SetError("Table name is required", nil, http.StatusBadRequest).LogAndResponse("no table name in request body", nil, true)
 	}
+	if status, err := checkConsistency(state, queryReq.Consistency); err != nil {
+		return state.SetError(err.Error(), err, status).LogAndResponse("consistency not valid or not allowed: "+queryReq.Consistency, nil, true)
+	}
 
 	// Find the user's database connection from TTL map
 	userDB, err := suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
 	if err != nil {
 		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
 	}
-
-	// Prepare response
-	response := suresql.QueryResponse{
-		Records:       []orm.DBRecord{},
-		ExecutionTime: 0,
-		Count:         0,
-	}
+	userDB = suresql.Node().RoutedConnection(userDB)
+	// Consistency from the request is only for this call, the user's connection keeps DBMS_CONSISTENCY
+	userDB = suresql.WithConsistency(userDB, queryReq.Consistency)
 
 	// Check if we have a condition
 	hasCondition := queryReq.Condition != nil && !isEmptyCondition(queryReq.Condition)
 
-	// Use the appropriate query function based on SingleRow and Condition
-	if queryReq.SingleRow {
-		if hasCondition {
-			// SelectOneWithCondition
-			state.Label += "SelectOneWithCondition"
-			record, err := userDB.SelectOneWithCondition(queryReq.Table, queryReq.Condition)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute SelectOneWithCondition", queryReq, true)
-				}
-			} else {
-				// Add single record to response
-				response.Records = append(response.Records, record)
-				response.Count = 1
-				state.LogMessage = "executed successfully"
-			}
-		} else {
-			// SelectOne
-			state.Label += "SelectOne"
-			record, err := userDB.SelectOne(queryReq.Table)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result (but not error)
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute SelectOne", queryReq, true)
-				}
-			} else {
-				// Add single record to response
-				response.Records = append(response.Records, record)
-				response.Count = 1
-				state.LogMessage = "executed successfully"
-			}
+	// Server-side caps (max_rows, max_response_bytes) so a query without condition cannot return the whole table
+	limiter := suresql.Node().NewResponseLimiter()
+
+	// Same SQL as the orm Select functions, sent with the ordered query so the columns come in the same request
+	var query string
+	var values []interface{}
+	switch {
+	case queryReq.SingleRow && hasCondition:
+		state.Label += "SelectOneWithCondition"
+		query, values = queryReq.Condition.ToSelectString(queryReq.Table)
+		if !strings.Contains(strings.ToUpper(query), "LIMIT") {
+			query += " LIMIT 1"
 		}
-	} else {
-		if hasCondition {
-			// SelectManyWithCondition
-			state.Label += "SelectManyWithCondition"
-			records, err := userDB.SelectManyWithCondition(queryReq.Table, queryReq.Condition)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute SelectManyWithCondition", queryReq, true)
-				}
-			} else {
-				response.Records = records
-				response.Count = len(records)
-				state.LogMessage = "executed successfully"
-			}
-		} else {
-			// SelectMany
-			state.Label += "SelectMany"
-			records, err := userDB.SelectMany(queryReq.Table)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute SelectMany", queryReq, true)
-				}
-			} else {
-				response.Records = records
-				response.Count = len(records)
-				state.LogMessage = "executed successfully"
-			}
+	case queryReq.SingleRow:
+		state.Label += "SelectOne"
+		query = "SELECT * FROM " + queryReq.Table + " LIMIT 1"
+	case hasCondition:
+		state.Label += "SelectManyWithCondition"
+		queryReq.Condition.Limit = limiter.QueryLimit(queryReq.Condition.Limit)
+		query, values = queryReq.Condition.ToSelectString(queryReq.Table)
+	default:
+		// SelectMany, if there is max_rows then use the limit as the only condition
+		state.Label += "SelectMany"
+		query = "SELECT * FROM " + queryReq.Table
+		if limit := limiter.QueryLimit(0); limit > 0 {
+			query, values = (&orm.Condition{Limit: limit}).ToSelectString(queryReq.Table)
+		}
+	}
+
+	results, err := runQuery(userDB, []orm.ParametereizedSQL{{Query: query, Values: values}}, false, queryReq.IncludeColumns, limiter)
+	if err != nil {
+		return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReq, true)
+	}
+
+	response := suresql.QueryResponse{
+		Records: []orm.DBRecord{},
+	}
+	if len(results) > 0 {
+		response.Records = results[0].Records
+		response.Count = len(results[0].Records)
+		response.Truncated = results[0].Truncated
+		if queryReq.IncludeColumns {
+			response.Columns = results[0].Columns
 		}
 	}
+	// The table is known, not guessed from the SQL
+	for i := range response.Records {
+		response.Records[i].TableName = queryReq.Table
+	}
+	state.LogMessage = "executed successfully"
+	if response.Count == 0 {
+		state.LogMessage = "executed with no results"
+	} else if response.Truncated {
+		state.LogMessage = "executed successfully, truncated"
+	}
 
 	// Calculate total execution time
 	response.ExecutionTime = state.SaveStopTimer()
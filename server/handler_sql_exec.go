@@ -34,12 +34,18 @@ func HandleSQLExecution(ctx simplehttp.Context) error {
 		return state.SetError("No SQL statements provided", nil, http.StatusBadRequest).LogAndResponse("no sql statement in request body", nil, true)
 		// return returnErrorResponse(ctx, http.StatusBadRequest, "No SQL statements provided", nil)
 	}
+	if status, err := checkConsistency(state, sqlReq.Consistency); err != nil {
+		return state.SetError(err.Error(), err, status).LogAndResponse("consistency not valid or not allowed: "+sqlReq.Consistency, nil, true)
+	}
 
-	// Find the user's database connection from TTL map
-	userDB, err := suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
+	// Find the user's database connection from TTL map (or internal connection if forwarded from follower)
+	userDB, err := getUserConnection(ctx, state)
 	if err != nil {
 		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
 	}
+	userDB = suresql.Node().RoutedConnection(userDB)
+	// Consistency from the request is only for this call, the user's connection keeps DBMS_CONSISTENCY
+	userDB = suresql.WithConsistency(userDB, sqlReq.Consistency)
 
 	// Prepare response
 	response := suresql.SQLResponse{
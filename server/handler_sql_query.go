@@ -32,176 +32,82 @@ func HandleSQLQuery(ctx simplehttp.Context) error {
 		return state.SetError("No SQL statements provided", nil, http.StatusBadRequest).LogAndResponse("no sql statement in request body", nil, true)
 		// return returnErrorResponse(ctx, http.StatusBadRequest, "No SQL statements provided", nil)
 	}
+	if status, err := checkConsistency(state, queryReqSQL.Consistency); err != nil {
+		return state.SetError(err.Error(), err, status).LogAndResponse("consistency not valid or not allowed: "+queryReqSQL.Consistency, nil, true)
+	}
 
 	// Find the user's database connection from TTL map
 	userDB, err := suresql.CurrentNode.GetDBConnectionByToken(state.Token.Token)
 	if err != nil {
 		return state.SetError("Cannot get DB connection", err, http.StatusInternalServerError).LogAndResponse("cannot get DB connection, maybe disconnected", nil, true)
 	}
+	userDB = suresql.Node().RoutedConnection(userDB)
+	// Consistency from the request is only for this call, the user's connection keeps DBMS_CONSISTENCY
+	userDB = suresql.WithConsistency(userDB, queryReqSQL.Consistency)
 
-	// Prepare response
-	var reponseMulti suresql.QueryResponseSQL
+	// Raw statements are sent as parameterized without values
+	sqls := queryReqSQL.ParamSQL
+	label := "SelectSQLParameterized"
+	if len(queryReqSQL.Statements) > 0 {
+		label = "SelectSQL"
+		sqls = make([]orm.ParametereizedSQL, len(queryReqSQL.Statements))
+		for i, statement := range queryReqSQL.Statements {
+			sqls[i] = orm.ParametereizedSQL{Query: statement}
+		}
+	}
+	state.Label += label
 
-	// var executionType string
-	// var err error
+	// Server-side caps (max_rows per statement, max_response_bytes for all statements) are applied while the
+	// DBMS response is read, records over the caps are dropped before the next ones are read
+	limiter := suresql.Node().NewResponseLimiter()
+	results, err := runQuery(userDB, sqls, queryReqSQL.SingleRow, queryReqSQL.IncludeColumns, limiter)
+	if err != nil {
+		return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
+	}
 
-	// Execute the appropriate type of SQL statements
-	if len(queryReqSQL.Statements) > 0 {
-		// Raw SQL statements
-		if len(queryReqSQL.Statements) == 1 {
-			if queryReqSQL.SingleRow {
-				// Single raw SQL statement
-				state.Label += "SelectOnlyOneSQL"
-				// result := userDB.SelectOneSQL(sqlReq.Statements[0])
-				record, err := userDB.SelectOnlyOneSQL(queryReqSQL.Statements[0])
-				if err != nil {
-					if err == orm.ErrSQLNoRows {
-						// No results found - return empty result
-						state.LogMessage = "executed with no results"
-					} else {
-						return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-					}
-				} else {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       orm.DBRecords{record},
-						Count:         1,
-						ExecutionTime: state.SaveStopTimer(),
-					}
-					reponseMulti = append(reponseMulti, response)
-					state.LogMessage = "executed successfully"
-				}
-			} else {
-				// Single raw SQL statement
-				state.Label += "SelectOneSQL"
-				// result := userDB.SelectOneSQL(sqlReq.Statements[0])
-				records, err := userDB.SelectOneSQL(queryReqSQL.Statements[0])
-				if err != nil {
-					if err == orm.ErrSQLNoRows {
-						// No results found - return empty result
-						state.LogMessage = "executed with no results"
-					} else {
-						return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-					}
-				} else {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       records,
-						Count:         len(records),
-						ExecutionTime: state.SaveStopTimer(),
-					}
-					reponseMulti = append(reponseMulti, response)
-					state.LogMessage = "executed successfully"
-				}
-			}
-		} else {
-			// Multiple raw SQL statements
-			state.Label += "SelectManySQL"
-			records, err := userDB.SelectManySQL(queryReqSQL.Statements)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-				}
-			} else {
-				timing := state.SaveStopTimer()
-				for _, rs := range records {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       rs,
-						Count:         len(rs),
-						ExecutionTime: timing,
-					}
-					reponseMulti = append(reponseMulti, response)
-				}
-				state.LogMessage = "executed successfully"
-			}
+	// One statement without rows has no result like before, unless the columns are requested
+	timing := state.SaveStopTimer()
+	var reponseMulti suresql.QueryResponseSQL
+	for _, result := range results {
+		if len(sqls) == 1 && len(result.Records) == 0 && !result.Truncated && !queryReqSQL.IncludeColumns {
+			break
+		}
+		response := suresql.QueryResponse{
+			Records:       result.Records,
+			Count:         len(result.Records),
+			Truncated:     result.Truncated,
+			ExecutionTime: timing,
 		}
-	} else if len(queryReqSQL.ParamSQL) > 0 {
-		// Parameterized SQL statements
-		if len(queryReqSQL.ParamSQL) == 1 {
-			if queryReqSQL.SingleRow {
-				// Single raw SQL statement
-				state.Label += "SelectOnlyOneSQLParameterized"
-				// result := userDB.SelectOneSQL(sqlReq.Statements[0])
-				record, err := userDB.SelectOnlyOneSQLParameterized(queryReqSQL.ParamSQL[0])
-				if err != nil {
-					if err == orm.ErrSQLNoRows {
-						// No results found - return empty result
-						state.LogMessage = "executed with no results"
-					} else {
-						return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-					}
-				} else {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       orm.DBRecords{record},
-						Count:         1,
-						ExecutionTime: state.SaveStopTimer(),
-					}
-					reponseMulti = append(reponseMulti, response)
-					state.LogMessage = "executed successfully"
-				}
-			} else {
-				// Single parameterized SQL statement
-				state.Label += "SelectOneSQLParameterized"
-				records, err := userDB.SelectOneSQLParameterized(queryReqSQL.ParamSQL[0])
-				if err != nil {
-					if err == orm.ErrSQLNoRows {
-						// No results found - return empty result
-						state.LogMessage = "executed with no results"
-					} else {
-						return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-					}
-				} else {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       records,
-						Count:         len(records),
-						ExecutionTime: state.SaveStopTimer(),
-					}
-					reponseMulti = append(reponseMulti, response)
-					state.LogMessage = "executed successfully"
-				}
-			}
-		} else {
-			// Multiple parameterized SQL statements
-			state.Label += "SelectManySQLParameterized"
-			records, err := userDB.SelectManySQLParameterized(queryReqSQL.ParamSQL)
-			if err != nil {
-				if err == orm.ErrSQLNoRows {
-					// No results found - return empty result
-					state.LogMessage = "executed with no results"
-				} else {
-					return state.SetError("Failed to execute query", err, http.StatusInternalServerError).LogAndResponse("failed to execute "+state.Label, queryReqSQL, true)
-				}
-			} else {
-				timing := state.SaveStopTimer()
-				for _, rs := range records {
-					// Add single record to response
-					response := suresql.QueryResponse{
-						Records:       rs,
-						Count:         len(rs),
-						ExecutionTime: timing,
-					}
-					reponseMulti = append(reponseMulti, response)
-				}
-				state.LogMessage = "executed successfully"
-			}
+		if queryReqSQL.IncludeColumns {
+			response.Columns = result.Columns
 		}
+		reponseMulti = append(reponseMulti, response)
+	}
+	state.LogMessage = "executed successfully"
+	if len(reponseMulti) == 0 || (len(sqls) == 1 && reponseMulti[0].Count == 0) {
+		state.LogMessage = "executed with no results"
+	} else if limiter.IsTruncated {
+		state.LogMessage = "executed successfully, truncated"
 	}
-
-	// Check if only 1 row should be returned
-	// if queryReqSQL.SingleRow {
-	// 	for _, r := range reponseMulti {
-	// 		if r.Count > 1 {
-	// 			return state.SetError("Failed to execute query", orm.ErrSQLMoreThanOneRow, http.StatusBadRequest).LogAndResponse("select only 1 row, returned multiple "+state.Label, queryReqSQL, true)
-	// 		}
-	// 	}
-	// }
 
 	// Calculate total execution time
 	return state.SetSuccess("SQL executed successfully", reponseMulti).LogAndResponse("raw sql query executed successfully", reponseMulti, true)
 }
+
+// Run the SELECT statements in one DBMS request, records are kept only while they fit the limiter. With
+// singleRow and one statement, more than one row is orm.ErrSQLMoreThanOneRow (like SelectOnlyOneSQL).
+// With describe the columns have the nullable and primary key of the table.
+func runQuery(db suresql.SureSQLDB, sqls []orm.ParametereizedSQL, singleRow, describe bool, limiter *suresql.ResponseLimiter) ([]suresql.OrderedResult, error) {
+	if !singleRow || len(sqls) != 1 {
+		return suresql.QueryOrdered(db, sqls, limiter, describe)
+	}
+	// One row is kept, a second row makes the result truncated
+	maxRows := limiter.MaxRows
+	limiter.MaxRows = 1
+	results, err := suresql.QueryOrdered(db, sqls, limiter, describe)
+	limiter.MaxRows = maxRows
+	if err == nil && len(results) == 1 && results[0].Truncated && len(results[0].Records) == 1 {
+		return results, orm.ErrSQLMoreThanOneRow
+	}
+	return results, err
+}
@@ -155,7 +155,7 @@ func (h *HandlerState) OnlyLog(message string, data interface{}, restartTimer bo
 		ClientIP:      h.Header.RemoteIP, // NOTE: is this accurate??
 		ClientBrowser: h.Header.UserAgent,
 		ClientDevice:  h.Header.Device,
-		NodeNumber:    suresql.CurrentNode.Config.NodeNumber,
+		NodeNumber:    suresql.Node().Config.NodeNumber,
 		// Error:         h.ErrorMessage,
 		// RawQuery: ,
 	}
@@ -56,7 +56,7 @@ func RegisterInternalRoutes(server simplehttp.Server) {
 	internalAPI.Use(simplehttp.MiddlewareBasicAuth(
 		suresql.CurrentNode.InternalConfig.Username,
 		suresql.CurrentNode.InternalConfig.Password,
-	))
+	), MiddlewareDBMSConnected())
 	// fmt.Println("Using user:", suresql.CurrentNode.InternalConnection.Config.Username, " pass:", suresql.CurrentNode.InternalConnection.Config.Password)
 
 	// Register internal routes
@@ -67,6 +67,28 @@ func RegisterInternalRoutes(server simplehttp.Server) {
 	internalAPI.DELETE("/iusers", HandleDeleteUser)
 	internalAPI.GET("/schema", HandleGetSchema)
 	internalAPI.GET("/dbms_status", HandleDBMSStatus)
+	internalAPI.GET("/named", HandleListNamedQueries)
+	internalAPI.POST("/named", HandleCreateNamedQuery)
+	internalAPI.PUT("/named", HandleUpdateNamedQuery)
+	internalAPI.DELETE("/named", HandleDeleteNamedQuery)
+	internalAPI.GET("/rawsql_access", HandleGetRawSQLAccess)
+	internalAPI.PUT("/rawsql_access", HandleSetRawSQLAccess)
+	internalAPI.GET("/nodes", HandleListNodes)
+	internalAPI.POST("/nodes", HandleAddNode)
+	internalAPI.PUT("/nodes", HandleUpdateNode)
+	internalAPI.DELETE("/nodes", HandleDeleteNode)
+	internalAPI.GET("/backup", HandleBackup)
+	internalAPI.POST("/restore", HandleRestore)
+	internalAPI.GET("/sessions", HandleListSessions)
+	internalAPI.DELETE("/sessions", HandleRevokeSessions)
+	internalAPI.GET("/settings", HandleListSettings)
+	internalAPI.PUT("/settings", HandleSetSetting)
+	internalAPI.DELETE("/settings", HandleDeleteSetting)
+	internalAPI.GET("/settings/known", HandleKnownSettings)
+	internalAPI.POST("/reload", HandleReload)
+	// Writes forwarded from follower nodes, caller identity is in the headers
+	internalAPI.POST(FORWARD_SQL_PATH, ForwardedIdentity()(RawSQLAccessCheck()(HandleSQLExecution)))
+	internalAPI.POST(FORWARD_INSERT_PATH, ForwardedIdentity()(HandleInsert))
 }
 
 // HandleListUsers retrieves all users from the system (or filtered by username)
@@ -142,8 +164,8 @@ func HandleCreateUser(ctx simplehttp.Context) error {
 	// Hash the password
 	hashedPassword, err := encryption.HashPin(
 		createReq.Password,
-		suresql.CurrentNode.Config.APIKey,
-		suresql.CurrentNode.Config.ClientID,
+		suresql.Node().Config.APIKey,
+		suresql.Node().Config.ClientID,
 	)
 	if err != nil {
 		return state.SetError("Failed to hash password", err, http.StatusInternalServerError).LogAndResponse("failed to hash password", nil, true)
@@ -234,8 +256,8 @@ func HandleUpdateUser(ctx simplehttp.Context) error {
 	if updateReq.NewPassword != "" {
 		hashedPassword, err := encryption.HashPin(
 			updateReq.NewPassword,
-			suresql.CurrentNode.Config.APIKey,
-			suresql.CurrentNode.Config.ClientID,
+			suresql.Node().Config.APIKey,
+			suresql.Node().Config.ClientID,
 		)
 		if err != nil {
 			return state.SetError("Failed to hash password", err, http.StatusInternalServerError).LogAndResponse("failed to hash password", nil, true)
@@ -303,6 +325,8 @@ func HandleDeleteUser(ctx simplehttp.Context) error {
 	if result.Error != nil {
 		return state.SetError("Failed to delete user", err, http.StatusInternalServerError).LogAndResponse("failed to delete from db", nil, true)
 	}
+	// Deleted user must not keep using the tokens that are already issued
+	TokenStore.RevokeUser(username)
 
 	return state.SetSuccess("Users deleted successfully", nil).LogAndResponse(fmt.Sprintf("user %s deleted successfully", username), "ExecOneSQLParameterized", true)
 }
@@ -328,7 +352,10 @@ func HandleDBMSStatus(ctx simplehttp.Context) error {
 		if err != nil {
 			return state.SetError("DBMS status returns error", err, http.StatusInternalServerError).LogAndResponse("DBMS status returns error", err, true)
 		}
-		return state.SetSuccess("Get DBMS status successfully", result).LogAndResponse("get status DBMS successfully (should be internal)", "Status", true)
+		response := suresql.Node().StatusWithHealth(result)
+		drift := suresql.Node().DiscoverCluster()
+		response.Cluster = &drift
+		return state.SetSuccess("Get DBMS status successfully", response).LogAndResponse("get status DBMS successfully (should be internal)", "Status", true)
 	}
 
 	return state.SetError("DBMS status is not exposed to API", nil, http.StatusUnauthorized).LogAndResponse("DBMS status is not exposed to API", nil, true)
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/simplehttp"
+)
+
+// SessionInfo is an active session without the token values, so it is safe to be listed
+type SessionInfo struct {
+	UserID           string    `json:"user_id,omitempty"`
+	Username         string    `json:"username"`
+	RoleName         string    `json:"role_name,omitempty"`
+	TokenExpiresAt   time.Time `json:"token_expired_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expired_at"`
+}
+
+// HandleListSessions returns the active sessions (or only the sessions of ?username=)
+func HandleListSessions(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "list_sessions", "")
+
+	tokens := TokenStore.Sessions(ctx.GetQueryParam("username"))
+	sessions := make([]SessionInfo, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, SessionInfo{
+			UserID:           t.UserID,
+			Username:         t.UserName,
+			RoleName:         t.RoleName,
+			TokenExpiresAt:   t.TokenExpiresAt,
+			RefreshExpiresAt: t.RefreshExpiresAt,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Username != sessions[j].Username {
+			return sessions[i].Username < sessions[j].Username
+		}
+		return sessions[i].TokenExpiresAt.Before(sessions[j].TokenExpiresAt)
+	})
+	return state.SetSuccess(fmt.Sprintf("Sessions retrieved successfully: %d", len(sessions)), sessions).LogAndResponse(fmt.Sprintf("success count:%d", len(sessions)), nil, true)
+}
+
+// HandleRevokeSessions removes all tokens and pooled connections of ?username=, the user has to connect again
+func HandleRevokeSessions(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "revoke_sessions", "")
+
+	username := ctx.GetQueryParam("username")
+	if username == "" {
+		return state.SetError("Username is required", nil, http.StatusBadRequest).LogAndResponse("missing username field", nil, true)
+	}
+	revoked := TokenStore.RevokeUser(username)
+	return state.SetSuccess(fmt.Sprintf("Sessions revoked successfully: %d", revoked), map[string]interface{}{
+		"username": username,
+		"revoked":  revoked,
+	}).LogAndResponse(fmt.Sprintf("revoked %d sessions of %s", revoked, username), nil, true)
+}
+
+// HandleListSettings returns the settings, all or only the ?category= (and ?key=)
+func HandleListSettings(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "list_settings", suresql.SettingTable{}.TableName())
+
+	category := ctx.GetQueryParam("category")
+	key := ctx.GetQueryParam("key")
+	// Read once, a reload or setting change swaps the map
+	current := suresql.Node().Settings
+	if key != "" {
+		setting, ok := current.SettingExist(category, key)
+		if !ok {
+			return state.SetError("Setting "+category+"."+key+" not found", nil, http.StatusNotFound).LogAndResponse("setting not found", nil, true)
+		}
+		return state.SetSuccess("Setting retrieved successfully", []suresql.SettingTable{setting}).LogAndResponse("setting "+category+"."+key+" retrieved", nil, true)
+	}
+
+	var settings []suresql.SettingTable
+	for cat, m := range current {
+		if category != "" && cat != category {
+			continue
+		}
+		for _, s := range m {
+			settings = append(settings, s)
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Category != settings[j].Category {
+			return settings[i].Category < settings[j].Category
+		}
+		return settings[i].SettingKey < settings[j].SettingKey
+	})
+	return state.SetSuccess(fmt.Sprintf("Settings retrieved successfully: %d", len(settings)), settings).LogAndResponse(fmt.Sprintf("success count:%d", len(settings)), nil, true)
+}
+
+// HandleSetSetting saves (insert or replace) one setting then applies it to this node. The value is validated
+// against data_type and the rule of the known keys, data_type can be empty for the known keys.
+func HandleSetSetting(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "set_setting", suresql.SettingTable{}.TableName())
+
+	var setting suresql.SettingTable
+	if err := ctx.BindJSON(&setting); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+	if err := suresql.ValidateSetting(&setting); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid setting", nil, true)
+	}
+	setting.ID = 0
+
+	if err := suresql.SaveSettingToDB(&suresql.CurrentNode.InternalConnection, setting); err != nil {
+		return state.SetError("Failed to save setting", err, http.StatusInternalServerError).LogAndResponse("failed to save setting", nil, true)
+	}
+	suresql.ApplySettingChange(setting.Category, setting.SettingKey)
+	return state.SetSuccess("Setting saved successfully", setting).LogAndResponse(fmt.Sprintf("setting %s.%s saved", setting.Category, setting.SettingKey), nil, true)
+}
+
+// HandleDeleteSetting removes ?category= and ?key=, a known setting goes back to its default
+func HandleDeleteSetting(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "delete_setting", suresql.SettingTable{}.TableName())
+
+	category := ctx.GetQueryParam("category")
+	key := ctx.GetQueryParam("key")
+	if category == "" || key == "" {
+		return state.SetError("category and key are required", nil, http.StatusBadRequest).LogAndResponse("missing category or key", nil, true)
+	}
+	setting, ok := suresql.Node().Settings.SettingExist(category, key)
+	if !ok {
+		return state.SetError("Setting "+category+"."+key+" not found", nil, http.StatusNotFound).LogAndResponse("setting not found", nil, true)
+	}
+	if err := suresql.DeleteSettingFromDB(&suresql.CurrentNode.InternalConnection, category, key); err != nil {
+		return state.SetError("Failed to delete setting", err, http.StatusInternalServerError).LogAndResponse("failed to delete setting", nil, true)
+	}
+	suresql.ApplySettingChange(category, key)
+	return state.SetSuccess("Setting deleted successfully", setting).LogAndResponse(fmt.Sprintf("setting %s.%s deleted", category, key), nil, true)
+}
+
+// HandleKnownSettings returns the settings that SureSQL reads, with their type and rule
+func HandleKnownSettings(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "known_settings", suresql.SettingTable{}.TableName())
+
+	return state.SetSuccess(fmt.Sprintf("Known settings retrieved successfully: %d", len(suresql.KnownSettings)), suresql.KnownSettings).LogAndResponse("known settings retrieved", nil, false)
+}
+
+// HandleReload reads _configs and _settings again and applies them without restart, ?env=true reads the
+// .env files again as well
+func HandleReload(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "reload", suresql.SettingTable{}.TableName())
+
+	result, err := suresql.Reload(ctx.GetQueryParam("env") == "true")
+	if err != nil {
+		return state.SetError("Failed to reload settings", err, http.StatusInternalServerError).LogAndResponse("failed to reload settings", nil, true)
+	}
+	return state.SetSuccess("Settings reloaded successfully", result).LogAndResponse(fmt.Sprintf("settings reloaded, changed:%d", len(result.Changed)), nil, true)
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simplehttp"
+)
+
+// HandleBackup produces a consistent snapshot of the database. By default it is streamed to the caller,
+// with ?save=true it is written to the backup directory instead. ?format=sqlite (default) or sql (dump).
+func HandleBackup(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "backup", "")
+
+	format, err := suresql.BackupFormat(ctx.GetQueryParam("format"))
+	if err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid backup format", nil, true)
+	}
+
+	if ctx.GetQueryParam("save") == "true" {
+		result, err := suresql.Node().BackupToDir(format)
+		if err != nil {
+			return state.SetError("Backup failed", err, http.StatusInternalServerError).LogAndResponse("failed to write backup to "+suresql.Node().BackupDir, nil, true)
+		}
+		return state.SetSuccess("Backup saved successfully", result).LogAndResponse(fmt.Sprintf("backup saved to %s size:%d", result.File, result.Size), nil, true)
+	}
+
+	start := time.Now()
+	reader, err := suresql.CurrentNode.Backup(format)
+	if err != nil {
+		return state.SetError("Backup failed", err, http.StatusInternalServerError).LogAndResponse("failed to get backup from DBMS", nil, true)
+	}
+
+	contentType := "application/octet-stream"
+	if format == suresql.BACKUP_FORMAT_SQL {
+		contentType = "text/plain"
+	}
+	fileName := suresql.BACKUP_FILE_PREFIX + start.UTC().Format(suresql.BACKUP_TIME_FORMAT) + suresql.BackupExtension(format)
+	// The stream is read after this handler returns, so the log entry is made now and written at the end
+	entry := AccessLogTable{
+		Username:      state.User,
+		ActionType:    state.Label,
+		Method:        ctx.GetMethod(),
+		ClientIP:      state.Header.RemoteIP,
+		ClientBrowser: state.Header.UserAgent,
+		ClientDevice:  state.Header.Device,
+	}
+	stream := &backupStream{reader: reader, done: func(size int64, err error) {
+		entry.Duration = float64(time.Since(start).Microseconds()) / 1000
+		if err != nil {
+			entry.ResultStatus = ERROR_EVENT
+			entry.Result = "backup stream interrupted"
+			entry.Error = err.Error()
+		} else {
+			suresql.CurrentNode.MarkBackup(start)
+			entry.ResultStatus = SUCCESS_EVENT
+			entry.Result = fmt.Sprintf("backup streamed %s size:%d", fileName, size)
+		}
+		if lerr := entry.DBLogging(&suresql.CurrentNode.InternalConnection); lerr != nil {
+			simplelog.LogErrorStr("backup", lerr, "cannot log backup stream")
+		}
+	}}
+	ctx.SetResponseHeader("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	return ctx.Stream(http.StatusOK, contentType, stream)
+}
+
+// Backup from the DBMS as the response body. fasthttp reads it after the handler has returned and closes it
+// when done (or when the client is gone), done is called once with the size and nil if the whole backup was read.
+type backupStream struct {
+	reader io.ReadCloser
+	size   int64
+	done   func(size int64, err error)
+	once   sync.Once
+}
+
+func (b *backupStream) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.size += int64(n)
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *backupStream) Close() error {
+	b.finish(fmt.Errorf("closed after %d bytes", b.size))
+	return nil
+}
+
+func (b *backupStream) finish(err error) {
+	b.once.Do(func() {
+		b.reader.Close()
+		b.done(b.size, err)
+	})
+}
+
+// HandleRestore loads a backup (SQLite file or SQL dump, can be gzip) into the DBMS, replacing the whole
+// database. The backup is the request body, or ?file= to use a file from the backup directory.
+func HandleRestore(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "restore", "")
+
+	var data []byte
+	source := "request body"
+	if file := ctx.GetQueryParam("file"); file != "" {
+		var err error
+		source = file
+		data, err = suresql.Node().ReadBackupFile(file)
+		if err != nil {
+			return state.SetError("Cannot read backup file "+file, err, http.StatusBadRequest).LogAndResponse("cannot read backup file", nil, true)
+		}
+	} else {
+		data = ctx.GetBody()
+	}
+	if len(data) == 0 {
+		return state.SetError("Backup file is required", nil, http.StatusBadRequest).LogAndResponse("no backup in request", nil, true)
+	}
+
+	// Validate first so a wrong file is a bad request, not a failed restore
+	if _, _, err := suresql.DetectBackup(data); err != nil {
+		return state.SetError("Invalid backup: "+err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid backup from "+source, nil, true)
+	}
+	result, err := suresql.CurrentNode.Restore(data)
+	if err != nil {
+		return state.SetError("Restore failed", err, http.StatusInternalServerError).LogAndResponse("failed to restore from "+source, nil, true)
+	}
+	return state.SetSuccess("Restore completed successfully", result).LogAndResponse(fmt.Sprintf("restored %s from %s size:%d", result.Format, source, result.Size), nil, true)
+}
+
+// Write the result of the scheduled backup to the access logs
+func LogScheduledBackup(result suresql.BackupResult, err error) {
+	entry := AccessLogTable{
+		Username:     suresql.CurrentNode.InternalConfig.Username,
+		ActionType:   "scheduled_backup",
+		Duration:     result.Duration,
+		Method:       "SCHEDULE",
+		ResultStatus: SUCCESS_EVENT,
+		Result:       fmt.Sprintf("backup saved to %s size:%d", result.File, result.Size),
+	}
+	if err != nil {
+		entry.ResultStatus = ERROR_EVENT
+		entry.Result = "scheduled backup failed"
+		entry.Error = err.Error()
+	}
+	if lerr := entry.DBLogging(&suresql.CurrentNode.InternalConnection); lerr != nil {
+		simplelog.LogErrorStr("backup", lerr, "cannot log scheduled backup")
+	}
+}
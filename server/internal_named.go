@@ -0,0 +1,219 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/medatechnology/suresql"
+
+	orm "github.com/medatechnology/simpleorm"
+
+	"github.com/medatechnology/goutil/object"
+	"github.com/medatechnology/simplehttp"
+)
+
+// Name is used in the URL path, so keep it simple
+var namedQueryNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// RawSQLAccess represents which roles and users cannot use /sql and /querysql
+type RawSQLAccess struct {
+	DenyRoles []string `json:"deny_roles"`
+	DenyUsers []string `json:"deny_users"`
+}
+
+// HandleListNamedQueries retrieves all named queries (or filtered by name)
+func HandleListNamedQueries(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "list_named", suresql.NamedQueryTable{}.TableName())
+
+	var condition orm.Condition
+	nameFilter := ctx.GetQueryParam("name")
+	if nameFilter != "" {
+		condition = orm.Condition{
+			Field:    "name",
+			Operator: "LIKE",
+			Value:    "%" + nameFilter + "%",
+		}
+	}
+	condition.OrderBy = []string{"name ASC"}
+
+	queries := []suresql.NamedQueryTable{}
+	records, err := suresql.CurrentNode.InternalConnection.SelectManyWithCondition(suresql.NamedQueryTable{}.TableName(), &condition)
+	if err != nil && err != orm.ErrSQLNoRows {
+		return state.SetError("Failed to list named queries", err, http.StatusInternalServerError).LogAndResponse("failed to list named queries", nil, true)
+	}
+	for _, record := range records {
+		queries = append(queries, object.MapToStructSlowDB[suresql.NamedQueryTable](record.Data))
+	}
+
+	return state.SetSuccess(fmt.Sprintf("Named queries retrieved successfully: %d", len(queries)), queries).LogAndResponse(fmt.Sprintf("success count:%d", len(queries)), "SelectManyWithCondition", true)
+}
+
+// HandleCreateNamedQuery registers a new named query
+func HandleCreateNamedQuery(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "create_named", suresql.NamedQueryTable{}.TableName())
+
+	var createReq suresql.NamedQueryTable
+	if err := ctx.BindJSON(&createReq); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+
+	if err := validateNamedQuery(&createReq); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid named query", nil, true)
+	}
+
+	// No error means the name was found
+	if _, err := namedQueryExist(createReq.Name); err == nil {
+		return state.SetError("Named query already exists", nil, http.StatusConflict).LogAndResponse("named query already exists, cannot create", nil, true)
+	} else if err != orm.ErrSQLNoRows {
+		return state.SetError("Cannot read named query "+createReq.Name, err, http.StatusInternalServerError).LogAndResponse("cannot read named query "+createReq.Name, nil, true)
+	}
+
+	createReq.CreatedAt = time.Now().UTC()
+	rec, err := orm.TableStructToDBRecord(createReq)
+	if err != nil {
+		return state.SetError("Failed to create named query record", err, http.StatusInternalServerError).LogAndResponse("failed to convert struct to record", nil, true)
+	}
+	// remove ID so when inserting, id will be auto-generated (default value) from the DB
+	delete(rec.Data, "id")
+
+	res := suresql.CurrentNode.InternalConnection.InsertOneDBRecord(rec, false)
+	if res.Error != nil {
+		return state.SetError("Failed to create named query", res.Error, http.StatusInternalServerError).LogAndResponse("failed to insert db", nil, true)
+	}
+
+	return state.SetSuccess("Named query created successfully", createReq).LogAndResponse(fmt.Sprintf("named query %s created", createReq.Name), "InsertOneDBRecord", true)
+}
+
+// HandleUpdateNamedQuery updates the query, query_type or description of an existing named query
+func HandleUpdateNamedQuery(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "update_named", suresql.NamedQueryTable{}.TableName())
+
+	var updateReq suresql.NamedQueryTable
+	if err := ctx.BindJSON(&updateReq); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+	if updateReq.Name == "" {
+		return state.SetError("Name is required", nil, http.StatusBadRequest).LogAndResponse("missing name field", nil, true)
+	}
+
+	existing, err := namedQueryExist(updateReq.Name)
+	if err != nil {
+		return namedQueryError(&state, updateReq.Name, err)
+	}
+
+	// Only update the fields that are provided
+	if updateReq.Query != "" {
+		existing.Query = updateReq.Query
+		// Query changed but type is not provided, then guess it again
+		if updateReq.QueryType == "" {
+			existing.QueryType = ""
+		}
+	}
+	if updateReq.QueryType != "" {
+		existing.QueryType = updateReq.QueryType
+	}
+	if updateReq.Description != "" {
+		existing.Description = updateReq.Description
+	}
+	if err := validateNamedQuery(&existing); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid named query", nil, true)
+	}
+
+	result := suresql.CurrentNode.InternalConnection.ExecOneSQLParameterized(orm.ParametereizedSQL{
+		Query:  "UPDATE " + existing.TableName() + " SET query = ?, query_type = ?, description = ? WHERE name = ?",
+		Values: []interface{}{existing.Query, existing.QueryType, existing.Description, existing.Name},
+	})
+	if result.Error != nil {
+		return state.SetError("Failed to update named query", result.Error, http.StatusInternalServerError).LogAndResponse("failed to update db", nil, true)
+	}
+
+	return state.SetSuccess("Named query updated successfully", existing).LogAndResponse(fmt.Sprintf("named query %s updated", existing.Name), "ExecOneSQLParameterized", true)
+}
+
+// HandleDeleteNamedQuery removes a named query from the registry
+func HandleDeleteNamedQuery(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "delete_named", suresql.NamedQueryTable{}.TableName())
+
+	name := ctx.GetQueryParam("name")
+	if name == "" {
+		return state.SetError("Name is required", nil, http.StatusBadRequest).LogAndResponse("missing name field", nil, true)
+	}
+
+	if _, err := namedQueryExist(name); err != nil {
+		return namedQueryError(&state, name, err)
+	}
+
+	result := suresql.CurrentNode.InternalConnection.ExecOneSQLParameterized(orm.ParametereizedSQL{
+		Query:  "DELETE FROM " + suresql.NamedQueryTable{}.TableName() + " WHERE name = ?",
+		Values: []interface{}{name},
+	})
+	if result.Error != nil {
+		return state.SetError("Failed to delete named query", result.Error, http.StatusInternalServerError).LogAndResponse("failed to delete from db", nil, true)
+	}
+
+	return state.SetSuccess("Named query deleted successfully", nil).LogAndResponse(fmt.Sprintf("named query %s deleted successfully", name), "ExecOneSQLParameterized", true)
+}
+
+// HandleGetRawSQLAccess returns roles and users that cannot use the raw SQL end-points
+func HandleGetRawSQLAccess(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "get_rawsql_access", suresql.SettingTable{}.TableName())
+
+	access := RawSQLAccess{
+		DenyRoles: suresql.Node().Settings.SettingList(suresql.SETTING_CATEGORY_ACCESS, suresql.SETTING_KEY_RAW_SQL_DENY_ROLES),
+		DenyUsers: suresql.Node().Settings.SettingList(suresql.SETTING_CATEGORY_ACCESS, suresql.SETTING_KEY_RAW_SQL_DENY_USERS),
+	}
+	return state.SetSuccess("Raw SQL access retrieved successfully", access).LogAndResponse("raw sql access retrieved", nil, true)
+}
+
+// HandleSetRawSQLAccess replaces the roles and users that cannot use the raw SQL end-points
+func HandleSetRawSQLAccess(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "set_rawsql_access", suresql.SettingTable{}.TableName())
+
+	var access RawSQLAccess
+	if err := ctx.BindJSON(&access); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+
+	settings := []suresql.SettingTable{
+		{
+			Category:   suresql.SETTING_CATEGORY_ACCESS,
+			DataType:   "string",
+			SettingKey: suresql.SETTING_KEY_RAW_SQL_DENY_ROLES,
+			TextValue:  strings.Join(access.DenyRoles, suresql.SETTING_LIST_DELIMITER),
+		},
+		{
+			Category:   suresql.SETTING_CATEGORY_ACCESS,
+			DataType:   "string",
+			SettingKey: suresql.SETTING_KEY_RAW_SQL_DENY_USERS,
+			TextValue:  strings.Join(access.DenyUsers, suresql.SETTING_LIST_DELIMITER),
+		},
+	}
+	for _, s := range settings {
+		if err := suresql.SaveSettingToDB(&suresql.CurrentNode.InternalConnection, s); err != nil {
+			return state.SetError("Failed to save raw SQL access", err, http.StatusInternalServerError).LogAndResponse("failed to save setting", nil, true)
+		}
+	}
+
+	return state.SetSuccess("Raw SQL access updated successfully", access).LogAndResponse("raw sql access updated", nil, true)
+}
+
+// Check the mandatory fields and normalize the query_type
+func validateNamedQuery(q *suresql.NamedQueryTable) error {
+	if q.Name == "" || q.Query == "" {
+		return fmt.Errorf("name and query are required")
+	}
+	if !namedQueryNameRegex.MatchString(q.Name) {
+		return fmt.Errorf("name can only contain letters, numbers, dot, dash and underscore")
+	}
+	switch q.QueryType {
+	case suresql.NAMED_QUERY_TYPE_QUERY, suresql.NAMED_QUERY_TYPE_EXEC:
+	case "":
+		q.QueryType = suresql.GuessNamedQueryType(q.Query)
+	default:
+		return fmt.Errorf("query_type must be %s or %s", suresql.NAMED_QUERY_TYPE_QUERY, suresql.NAMED_QUERY_TYPE_EXEC)
+	}
+	return nil
+}
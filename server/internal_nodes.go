@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/medatechnology/suresql"
+
+	"github.com/medatechnology/goutil/object"
+	"github.com/medatechnology/simplehttp"
+)
+
+// NodeUpdateRequest changes an existing node, only the fields that are provided
+type NodeUpdateRequest struct {
+	NodeNumber int    `json:"node_number"`
+	Hostname   string `json:"hostname,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Port       string `json:"port,omitempty"`
+}
+
+// HandleListNodes returns all nodes of the cluster from the nodes settings (including this node)
+func HandleListNodes(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "list_nodes", suresql.SettingTable{}.TableName())
+
+	nodes := suresql.Node().NodeSettings()
+	return state.SetSuccess(fmt.Sprintf("Nodes retrieved successfully: %d", len(nodes)), nodes).LogAndResponse(fmt.Sprintf("success count:%d", len(nodes)), nil, true)
+}
+
+// HandleAddNode adds a new peer to the cluster
+func HandleAddNode(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "add_node", suresql.SettingTable{}.TableName())
+
+	var node suresql.NodeSetting
+	if err := ctx.BindJSON(&node); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+	if err := node.Validate(); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid node", nil, true)
+	}
+	if _, ok := suresql.Node().NodeSettingExist(node.NodeNumber); ok {
+		return state.SetError(fmt.Sprintf("Node %d already exists", node.NodeNumber), nil, http.StatusConflict).LogAndResponse("node already exists, cannot add", nil, true)
+	}
+	// Key is unique in the nodes category, do not overwrite other node
+	setting := node.ToSetting()
+	if _, ok := suresql.Node().Settings.SettingExist(suresql.SETTING_CATEGORY_NODES, setting.SettingKey); ok {
+		return state.SetError("Node key "+setting.SettingKey+" already exists", nil, http.StatusConflict).LogAndResponse("node key already exists, cannot add", nil, true)
+	}
+
+	if err := saveNode(setting); err != nil {
+		return state.SetError("Failed to add node", err, http.StatusInternalServerError).LogAndResponse("failed to save setting", nil, true)
+	}
+	node.Key = setting.SettingKey
+	return state.SetSuccess("Node added successfully", node).LogAndResponse(fmt.Sprintf("node %d added", node.NodeNumber), nil, true)
+}
+
+// HandleUpdateNode changes the mode (or hostname, ip, port) of an existing node
+func HandleUpdateNode(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "update_node", suresql.SettingTable{}.TableName())
+
+	var updateReq NodeUpdateRequest
+	if err := ctx.BindJSON(&updateReq); err != nil {
+		return state.SetError("Invalid request format", err, http.StatusBadRequest).LogAndResponse("failed to parse request body", nil, true)
+	}
+
+	node, ok := suresql.Node().NodeSettingExist(updateReq.NodeNumber)
+	if !ok {
+		return state.SetError(fmt.Sprintf("Node %d not found", updateReq.NodeNumber), nil, http.StatusNotFound).LogAndResponse("node not found", nil, true)
+	}
+	if updateReq.Hostname != "" {
+		node.Hostname = updateReq.Hostname
+	}
+	if updateReq.IP != "" {
+		node.IP = updateReq.IP
+	}
+	if updateReq.Mode != "" {
+		node.Mode = updateReq.Mode
+	}
+	if updateReq.Port != "" {
+		node.Port = updateReq.Port
+	}
+	if err := node.Validate(); err != nil {
+		return state.SetError(err.Error(), err, http.StatusBadRequest).LogAndResponse("invalid node", nil, true)
+	}
+
+	if err := saveNode(node.ToSetting()); err != nil {
+		return state.SetError("Failed to update node", err, http.StatusInternalServerError).LogAndResponse("failed to save setting", nil, true)
+	}
+	return state.SetSuccess("Node updated successfully", node).LogAndResponse(fmt.Sprintf("node %d updated", node.NodeNumber), nil, true)
+}
+
+// HandleDeleteNode removes a peer from the cluster, this node cannot be removed
+func HandleDeleteNode(ctx simplehttp.Context) error {
+	state := NewHandlerState(ctx, suresql.CurrentNode.InternalConfig.Username, "delete_node", suresql.SettingTable{}.TableName())
+
+	numStr := ctx.GetQueryParam("node_number")
+	if numStr == "" {
+		return state.SetError("node_number is required", nil, http.StatusBadRequest).LogAndResponse("missing node_number", nil, true)
+	}
+	num := object.Int(numStr, false)
+
+	node, ok := suresql.Node().NodeSettingExist(num)
+	if !ok {
+		return state.SetError(fmt.Sprintf("Node %d not found", num), nil, http.StatusNotFound).LogAndResponse("node not found", nil, true)
+	}
+	if node.IsThisNode {
+		return state.SetError("Cannot remove this node", nil, http.StatusBadRequest).LogAndResponse("trying to remove this node", nil, true)
+	}
+
+	if err := suresql.DeleteSettingFromDB(&suresql.CurrentNode.InternalConnection, suresql.SETTING_CATEGORY_NODES, node.Key); err != nil {
+		return state.SetError("Failed to remove node", err, http.StatusInternalServerError).LogAndResponse("failed to delete setting", nil, true)
+	}
+	suresql.ApplySettingChange(suresql.SETTING_CATEGORY_NODES, node.Key)
+	return state.SetSuccess("Node removed successfully", node).LogAndResponse(fmt.Sprintf("node %d removed", node.NodeNumber), nil, true)
+}
+
+// Save the node setting then re-apply so peers (and routing) are updated without restart
+func saveNode(setting suresql.SettingTable) error {
+	if err := suresql.SaveSettingToDB(&suresql.CurrentNode.InternalConnection, setting); err != nil {
+		return err
+	}
+	suresql.ApplySettingChange(suresql.SETTING_CATEGORY_NODES, setting.SettingKey)
+	return nil
+}
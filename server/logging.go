@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/medatechnology/suresql"
@@ -9,6 +11,9 @@ import (
 	orm "github.com/medatechnology/simpleorm"
 )
 
+// Access log writes that are not finished, waited for on shutdown
+var pendingLogs atomic.Int64
+
 type AccessLogTable struct {
 	ID            int       `json:"id,omitempty"              db:"id"`
 	Username      string    `json:"username,omitempty"        db:"username"`
@@ -35,7 +40,7 @@ func (l AccessLogTable) TableName() string {
 
 // Save the logentry to log table
 func (l *AccessLogTable) DBLogging(db *suresql.SureSQLDB) error {
-	l.NodeNumber = suresql.CurrentNode.Config.NodeNumber
+	l.NodeNumber = suresql.Node().Config.NodeNumber
 	l.Occurred = time.Now().UTC()
 	// l.Username = db.Config.Username
 	// return db.InsertOneTableStruct(l)
@@ -43,6 +48,8 @@ func (l *AccessLogTable) DBLogging(db *suresql.SureSQLDB) error {
 }
 
 func DBLogging(db suresql.SureSQLDB, entry AccessLogTable) error {
+	pendingLogs.Add(1)
+	defer pendingLogs.Add(-1)
 
 	// result := db.InsertOneTableStruct(entry, false)
 	sql := `INSERT INTO %s (username, action_type, duration, result,
@@ -72,3 +79,15 @@ func DBLogging(db suresql.SureSQLDB, entry AccessLogTable) error {
 	// }
 	return result.Error
 }
+
+// Wait for the access log writes in progress, until ctx is done
+func FlushAccessLogs(ctx context.Context) error {
+	for pendingLogs.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d access logs not written: %w", pendingLogs.Load(), ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
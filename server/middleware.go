@@ -14,6 +14,23 @@ const (
 	TOKEN_TABLE_STRING = "token"
 )
 
+// Until the internal DBMS is connected every API answers 503, the config (API key) is not loaded yet either
+func MiddlewareDBMSConnected() simplehttp.Middleware {
+	return simplehttp.WithName("DBMS connected", DBMSConnectedCheck())
+}
+
+func DBMSConnectedCheck() simplehttp.MiddlewareFunc {
+	return func(next simplehttp.HandlerFunc) simplehttp.HandlerFunc {
+		return func(ctx simplehttp.Context) error {
+			if !suresql.IsDBMSConnected() {
+				state := NewMiddlewareState(ctx, "DBMS")
+				return state.SetError(suresql.MESSAGE_DBMS_NOT_CONNECTED, nil, http.StatusServiceUnavailable).LogAndResponse("DBMS not connected", nil, true)
+			}
+			return next(ctx)
+		}
+	}
+}
+
 // AuthMiddleware verifies API key and client ID from request headers
 func MiddlewareAPIKeyHeader() simplehttp.Middleware {
 	return simplehttp.WithName("APIKeyClientID", APIKeyClientIDHeader())
@@ -32,7 +49,7 @@ func APIKeyClientIDHeader() simplehttp.MiddlewareFunc {
 				return state.SetError("API key required", nil, http.StatusUnauthorized).LogAndResponse("API key not provided", nil, true)
 			}
 
-			if suresql.CurrentNode.Config.APIKey != apiKey {
+			if suresql.Node().Config.APIKey != apiKey {
 				return state.SetError("Invalid API key", nil, http.StatusUnauthorized).LogAndResponse("Invalid API key", nil, true)
 			}
 
@@ -42,7 +59,7 @@ func APIKeyClientIDHeader() simplehttp.MiddlewareFunc {
 				return state.SetError("Client ID required", nil, http.StatusUnauthorized).LogAndResponse("Client ID not provided", nil, true)
 			}
 
-			if suresql.CurrentNode.Config.ClientID != clientID {
+			if suresql.Node().Config.ClientID != clientID {
 				return state.SetError("Invalid Client ID", nil, http.StatusUnauthorized).LogAndResponse("Invalid Client ID", nil, true)
 			}
 
@@ -89,3 +106,26 @@ func TokenValidationFromTTL() simplehttp.MiddlewareFunc {
 		}
 	}
 }
+
+// Only for the raw SQL end-points (/sql and /querysql), the user or role can be disabled from sending
+// arbitrary SQL, then they can only use /named. Needs TokenValidationFromTTL before this.
+func RawSQLAccessCheck() simplehttp.MiddlewareFunc {
+	return func(next simplehttp.HandlerFunc) simplehttp.HandlerFunc {
+		return func(ctx simplehttp.Context) error {
+			state := NewMiddlewareState(ctx, "raw sql")
+
+			tok, ok := ctx.Get(TOKEN_TABLE_STRING).(*suresql.TokenTable)
+			if !ok || tok == nil {
+				return state.SetError("Authentication token required", nil, http.StatusUnauthorized).LogAndResponse("no token", nil, true)
+			}
+
+			if !suresql.Node().IsRawSQLAllowed(tok.UserName, tok.RoleName) {
+				state.User = tok.UserName
+				return state.SetError("Raw SQL is disabled for this user, use named queries instead", nil, http.StatusForbidden).
+					LogAndResponse("raw sql denied for user:"+tok.UserName+" role:"+tok.RoleName, nil, true)
+			}
+
+			return next(ctx)
+		}
+	}
+}
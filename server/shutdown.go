@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/medatechnology/suresql"
+
+	utils "github.com/medatechnology/goutil"
+	"github.com/medatechnology/goutil/simplelog"
+	"github.com/medatechnology/simplehttp"
+)
+
+const (
+	DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+	SHUTDOWN_TIMEOUT_ENV     = "SURESQL_SHUTDOWN_TIMEOUT"
+	FLUSH_LOGS_TIMEOUT       = 5 * time.Second // after the handlers deadline
+)
+
+// Deadline for Shutdown, from the environment
+func ShutdownTimeout() time.Duration {
+	timeout := utils.GetEnvDuration(SHUTDOWN_TIMEOUT_ENV, DEFAULT_SHUTDOWN_TIMEOUT)
+	if timeout <= 0 {
+		return DEFAULT_SHUTDOWN_TIMEOUT
+	}
+	return timeout
+}
+
+// Shutdown stops accepting connections and waits for the running handlers up to timeout, stops the
+// background loops, then waits up to FLUSH_LOGS_TIMEOUT for the access logs still being written (ie: by
+// handlers that passed the deadline). The connections are closed only when nothing uses them anymore, the
+// internal connection last because the logs are written with it.
+func Shutdown(srv simplehttp.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		simplelog.LogErrorStr("shutdown", err, "handlers still running after "+timeout.String())
+	}
+	suresql.CurrentNode.StopBackground()
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), FLUSH_LOGS_TIMEOUT)
+	defer cancelFlush()
+	if lerr := FlushAccessLogs(flushCtx); lerr != nil {
+		simplelog.LogErrorStr("shutdown", lerr, "cannot flush access logs")
+		if err == nil {
+			err = lerr
+		}
+	}
+	if err != nil {
+		// Handlers or logs can still be using them, the process exit closes them
+		simplelog.LogFormat("shutdown not clean, connections are left open")
+		return err
+	}
+	closed := suresql.CurrentNode.CloseDBConnections()
+	if suresql.CurrentNode.InternalConnection != nil {
+		suresql.CloseDatabase(suresql.CurrentNode.InternalConnection)
+	}
+	simplelog.LogFormat("shutdown done, %d pooled connections closed", closed)
+	return nil
+}
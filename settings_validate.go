@@ -0,0 +1,179 @@
+package suresql
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Data types of the settings, the aliases (text, integer, double, boolean) are also read by GetValue
+const (
+	SETTING_TYPE_STRING = "string"
+	SETTING_TYPE_INT    = "int"
+	SETTING_TYPE_FLOAT  = "float"
+	SETTING_TYPE_BOOL   = "bool"
+)
+
+// Settings that SureSQL reads, the value must have this type and fit the rule
+type KnownSetting struct {
+	Category    string   `json:"category"`
+	SettingKey  string   `json:"setting_key"`
+	DataType    string   `json:"data_type"`
+	Min         int      `json:"min,omitempty"`     // int only
+	Options     []string `json:"options,omitempty"` // string only, empty means any
+	Description string   `json:"description,omitempty"`
+}
+
+// Nodes and token overrides are not here, their keys are free and the value is checked by ParseNodeSetting
+// and ParseTokenExpiry
+var KnownSettings = []KnownSetting{
+	{Category: SETTING_CATEGORY_TOKEN, SettingKey: SETTING_KEY_TOKEN_EXP, DataType: SETTING_TYPE_INT, Min: 1, Description: "access token expiry in minutes"},
+	{Category: SETTING_CATEGORY_TOKEN, SettingKey: SETTING_KEY_REFRESH_EXP, DataType: SETTING_TYPE_INT, Min: 1, Description: "refresh token expiry in minutes"},
+	{Category: SETTING_CATEGORY_TOKEN, SettingKey: SETTING_KEY_TOKEN_TTL, DataType: SETTING_TYPE_INT, Min: 1, Description: "minutes between the checks for expired tokens"},
+	{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_MAX_POOL, DataType: SETTING_TYPE_INT, Description: "max pooled connections per node"},
+	{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, DataType: SETTING_TYPE_BOOL, Description: "connection per token, otherwise the internal connection is used"},
+	{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, DataType: SETTING_TYPE_INT, Description: "max rows per query response, 0 no limit"},
+	{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_RESPONSE_BYTES, DataType: SETTING_TYPE_INT, Description: "max records size per query response, 0 no limit"},
+	{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_ROUTING_STRATEGY, DataType: SETTING_TYPE_STRING, Options: []string{ROUTING_ROUND_ROBIN, ROUTING_LEAST_LATENCY}, Description: "how reads are spread to the peers"},
+	{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_PEER_RETRY_AFTER, DataType: SETTING_TYPE_INT, Description: "seconds a peer that is down is not used, 0 default"},
+	{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_WRITE_FORWARD, DataType: SETTING_TYPE_STRING, Options: []string{WRITE_FORWARD_PROXY, WRITE_FORWARD_REDIRECT, WRITE_FORWARD_OFF}, Description: "how a follower handles writes"},
+	{Category: SETTING_CATEGORY_HEALTH, SettingKey: SETTING_KEY_HEALTH_INTERVAL, DataType: SETTING_TYPE_INT, Description: "seconds between peer checks, 0 disabled"},
+	{Category: SETTING_CATEGORY_BACKUP, SettingKey: SETTING_KEY_BACKUP_DIR, DataType: SETTING_TYPE_STRING, Description: "directory for the backup files"},
+	{Category: SETTING_CATEGORY_BACKUP, SettingKey: SETTING_KEY_BACKUP_INTERVAL, DataType: SETTING_TYPE_INT, Description: "minutes between scheduled backups, 0 disabled"},
+	{Category: SETTING_CATEGORY_BACKUP, SettingKey: SETTING_KEY_BACKUP_KEEP, DataType: SETTING_TYPE_INT, Description: "backup files to keep, 0 keep all"},
+	{Category: SETTING_CATEGORY_BACKUP, SettingKey: SETTING_KEY_BACKUP_GZIP, DataType: SETTING_TYPE_BOOL, Description: "compress the backup files"},
+	{Category: SETTING_CATEGORY_RELOAD, SettingKey: SETTING_KEY_RELOAD_INTERVAL, DataType: SETTING_TYPE_INT, Description: "seconds between settings reloads, 0 disabled"},
+	{Category: SETTING_CATEGORY_ACCESS, SettingKey: SETTING_KEY_RAW_SQL_DENY_ROLES, DataType: SETTING_TYPE_STRING, Description: "roles that cannot use raw SQL, comma separated"},
+	{Category: SETTING_CATEGORY_ACCESS, SettingKey: SETTING_KEY_RAW_SQL_DENY_USERS, DataType: SETTING_TYPE_STRING, Description: "users that cannot use raw SQL, comma separated"},
+	{Category: SETTING_CATEGORY_ACCESS, SettingKey: SETTING_KEY_CONSISTENCY_DENY_ROLES, DataType: SETTING_TYPE_STRING, Description: "roles that cannot request linearizable or strong, comma separated"},
+}
+
+// Categories with free keys whose value is text, checked by ParseNodeSetting and ParseTokenExpiry
+var textCategories = []string{SETTING_CATEGORY_NODES, SETTING_CATEGORY_TOKEN_ROLE, SETTING_CATEGORY_TOKEN_USER}
+
+func KnownSettingExist(category, key string) (KnownSetting, bool) {
+	for _, k := range KnownSettings {
+		if k.Category == category && k.SettingKey == key {
+			return k, true
+		}
+	}
+	return KnownSetting{}, false
+}
+
+// Canonical data type from the aliases, empty if unknown
+func NormalizeDataType(dataType string) string {
+	switch strings.ToLower(dataType) {
+	case "string", "text":
+		return SETTING_TYPE_STRING
+	case "int", "integer":
+		return SETTING_TYPE_INT
+	case "float", "double":
+		return SETTING_TYPE_FLOAT
+	case "bool", "boolean":
+		return SETTING_TYPE_BOOL
+	}
+	return ""
+}
+
+// ValidateSetting checks the value against the data type (and the rule of the known keys) and keeps only
+// the column of the type. Numbers and bool can be sent as text_value, they are moved to the right column.
+// Without data_type the type of the known key is used, or string.
+func ValidateSetting(s *SettingTable) error {
+	if s.Category == "" || s.SettingKey == "" {
+		return fmt.Errorf("category and setting_key are required")
+	}
+	known, isKnown := KnownSettingExist(s.Category, s.SettingKey)
+	if s.DataType == "" {
+		s.DataType = SETTING_TYPE_STRING
+		if isKnown {
+			s.DataType = known.DataType
+		}
+	}
+	dataType := NormalizeDataType(s.DataType)
+	if dataType == "" {
+		return fmt.Errorf("data_type must be string, int, float or bool")
+	}
+	// Old rows have bool as int (pool_on), they are changed to bool
+	if isKnown && dataType == SETTING_TYPE_INT && known.DataType == SETTING_TYPE_BOOL {
+		dataType = SETTING_TYPE_BOOL
+	}
+	if isKnown && dataType != known.DataType {
+		return fmt.Errorf("%s.%s must be %s", s.Category, s.SettingKey, known.DataType)
+	}
+	// Free keys of these categories are parsed from text_value below, another type would skip the parsing
+	if !isKnown && dataType != SETTING_TYPE_STRING && slices.Contains(textCategories, s.Category) {
+		return fmt.Errorf("%s settings must be %s", s.Category, SETTING_TYPE_STRING)
+	}
+	s.DataType = dataType
+	text := strings.TrimSpace(s.TextValue)
+
+	switch dataType {
+	case SETTING_TYPE_INT:
+		if text != "" {
+			v, err := strconv.Atoi(text)
+			if err != nil {
+				return fmt.Errorf("%s.%s needs an integer value, got %q", s.Category, s.SettingKey, s.TextValue)
+			}
+			s.IntValue = v
+		} else if s.FloatValue != 0 {
+			if s.FloatValue != math.Trunc(s.FloatValue) {
+				return fmt.Errorf("%s.%s needs an integer value, got %v", s.Category, s.SettingKey, s.FloatValue)
+			}
+			s.IntValue = int(s.FloatValue)
+		}
+		if s.IntValue < known.Min {
+			return fmt.Errorf("%s.%s cannot be less than %d", s.Category, s.SettingKey, known.Min)
+		}
+		s.TextValue, s.FloatValue = "", 0
+	case SETTING_TYPE_FLOAT:
+		if text != "" {
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return fmt.Errorf("%s.%s needs a float value, got %q", s.Category, s.SettingKey, s.TextValue)
+			}
+			s.FloatValue = v
+		} else if s.FloatValue == 0 {
+			s.FloatValue = float64(s.IntValue)
+		}
+		s.TextValue, s.IntValue = "", 0
+	case SETTING_TYPE_BOOL:
+		// bool is stored as int 0 or 1
+		if text != "" {
+			// yes and no are also read by GetValue
+			v, err := strconv.ParseBool(strings.NewReplacer("yes", "true", "no", "false").Replace(strings.ToLower(text)))
+			if err != nil {
+				return fmt.Errorf("%s.%s needs true or false, got %q", s.Category, s.SettingKey, s.TextValue)
+			}
+			s.IntValue = 0
+			if v {
+				s.IntValue = 1
+			}
+		}
+		if s.IntValue != 0 && s.IntValue != 1 {
+			return fmt.Errorf("%s.%s needs 0 or 1, got %d", s.Category, s.SettingKey, s.IntValue)
+		}
+		s.TextValue, s.FloatValue = "", 0
+	case SETTING_TYPE_STRING:
+		if len(known.Options) > 0 && !slices.Contains(known.Options, s.TextValue) {
+			return fmt.Errorf("%s.%s must be one of %s", s.Category, s.SettingKey, strings.Join(known.Options, ", "))
+		}
+		if s.Category == SETTING_CATEGORY_TOKEN_ROLE || s.Category == SETTING_CATEGORY_TOKEN_USER {
+			if _, err := ParseTokenExpiry(*s); err != nil {
+				return err
+			}
+		}
+		if s.Category == SETTING_CATEGORY_NODES {
+			ns, err := ParseNodeSetting(*s)
+			if err != nil {
+				return err
+			}
+			if err := ns.Validate(); err != nil {
+				return fmt.Errorf("node %s: %w", s.SettingKey, err)
+			}
+		}
+		s.IntValue, s.FloatValue = 0, 0
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package suresql
+
+import "testing"
+
+func TestValidateSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      SettingTable
+		want    SettingTable
+		wantErr bool
+	}{
+		{
+			name: "int from text",
+			in:   SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, TextValue: " 500 "},
+			want: SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, DataType: SETTING_TYPE_INT, IntValue: 500},
+		},
+		{
+			name: "int from whole float",
+			in:   SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, FloatValue: 20},
+			want: SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, DataType: SETTING_TYPE_INT, IntValue: 20},
+		},
+		{
+			name:    "int from fraction",
+			in:      SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, FloatValue: 2.5},
+			wantErr: true,
+		},
+		{
+			name:    "int not a number",
+			in:      SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, TextValue: "many"},
+			wantErr: true,
+		},
+		{
+			name:    "int below min",
+			in:      SettingTable{Category: SETTING_CATEGORY_TOKEN, SettingKey: SETTING_KEY_TOKEN_EXP, IntValue: 0},
+			wantErr: true,
+		},
+		{
+			name:    "known key with other type",
+			in:      SettingTable{Category: SETTING_CATEGORY_LIMIT, SettingKey: SETTING_KEY_MAX_ROWS, DataType: SETTING_TYPE_STRING, TextValue: "10"},
+			wantErr: true,
+		},
+		{
+			name: "bool from yes",
+			in:   SettingTable{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, TextValue: "Yes"},
+			want: SettingTable{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, DataType: SETTING_TYPE_BOOL, IntValue: 1},
+		},
+		{
+			name: "bool stored as int",
+			in:   SettingTable{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, DataType: "integer", IntValue: 1},
+			want: SettingTable{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, DataType: SETTING_TYPE_BOOL, IntValue: 1},
+		},
+		{
+			name:    "bool out of range",
+			in:      SettingTable{Category: SETTING_CATEGORY_CONNECTION, SettingKey: SETTING_KEY_ENABLE_POOL, IntValue: 2},
+			wantErr: true,
+		},
+		{
+			name: "string option",
+			in:   SettingTable{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_ROUTING_STRATEGY, TextValue: ROUTING_LEAST_LATENCY},
+			want: SettingTable{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_ROUTING_STRATEGY, DataType: SETTING_TYPE_STRING, TextValue: ROUTING_LEAST_LATENCY},
+		},
+		{
+			name:    "string not an option",
+			in:      SettingTable{Category: SETTING_CATEGORY_ROUTING, SettingKey: SETTING_KEY_ROUTING_STRATEGY, TextValue: "random"},
+			wantErr: true,
+		},
+		{
+			name: "unknown key float",
+			in:   SettingTable{Category: "app", SettingKey: "ratio", DataType: "double", TextValue: "0.25"},
+			want: SettingTable{Category: "app", SettingKey: "ratio", DataType: SETTING_TYPE_FLOAT, FloatValue: 0.25},
+		},
+		{
+			name: "unknown key defaults to string",
+			in:   SettingTable{Category: "app", SettingKey: "name", TextValue: "shop", IntValue: 3},
+			want: SettingTable{Category: "app", SettingKey: "name", DataType: SETTING_TYPE_STRING, TextValue: "shop"},
+		},
+		{
+			name:    "unknown data type",
+			in:      SettingTable{Category: "app", SettingKey: "name", DataType: "date"},
+			wantErr: true,
+		},
+		{
+			name: "token role override",
+			in:   SettingTable{Category: SETTING_CATEGORY_TOKEN_ROLE, SettingKey: "admin", TextValue: "30|"},
+			want: SettingTable{Category: SETTING_CATEGORY_TOKEN_ROLE, SettingKey: "admin", DataType: SETTING_TYPE_STRING, TextValue: "30|"},
+		},
+		{
+			name:    "token role override not text",
+			in:      SettingTable{Category: SETTING_CATEGORY_TOKEN_ROLE, SettingKey: "admin", DataType: SETTING_TYPE_INT, IntValue: 30},
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			in:      SettingTable{Category: "app"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			err := ValidateSetting(&got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package suresql
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CurrentNode is changed at run-time (reload, setting change, reconnect, status) while the handlers and the
+// background loops are reading it. The writers change CurrentNode under nodeLock then publish a copy of it,
+// the readers use Node() which is never changed after it is published. The maps in the node (Settings,
+// Status.Peers) are replaced by the writers, not changed in place, so the copy can share them.
+var (
+	nodeLock     sync.Mutex
+	nodeSnapshot atomic.Pointer[SureSQLNode]
+)
+
+// Node returns the last published copy of CurrentNode, read only. The methods that change the node or the
+// pool (ie: MarkBackup, AddDBConnection) are called on CurrentNode.
+func Node() *SureSQLNode {
+	if n := nodeSnapshot.Load(); n != nil {
+		return n
+	}
+	return &SureSQLNode{}
+}
+
+// Change CurrentNode then publish it, also when the change returns an error (it can be half done)
+func updateNode(change func(n *SureSQLNode) error) error {
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+	err := change(&CurrentNode)
+	publishNode()
+	return err
+}
+
+// The caller holds nodeLock, the pool is swapped under its own lock
+func publishNode() {
+	poolLock.RLock()
+	snapshot := CurrentNode
+	poolLock.RUnlock()
+	nodeSnapshot.Store(&snapshot)
+}
@@ -27,7 +27,12 @@ func NewDatabase(conf SureSQLDBMSConfig) (SureSQLDB, error) {
 		RetryCount:  conf.MaxRetries,
 	}
 	SchemaTable = rqlite.SCHEMA_TABLE
-	// TODO: make this read from environment
-	CurrentNode.Status.DBMSDriver = "direct-rqlite"
 	return rqlite.NewDatabase(config)
 }
+
+// The connection has no Close in orm, rqlite is an HTTP client so its idle keep-alive connections are closed
+func CloseDatabase(db SureSQLDB) {
+	if r, ok := db.(*rqlite.RQLiteDirectDB); ok && r.HTTPClient != nil {
+		r.HTTPClient.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,75 @@
+package suresql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token expiry override of a role or user from the token_role and token_user settings, zero uses the next
+// one: user, then role, then the token settings (Config.TokenExp and Config.RefreshExp)
+type TokenExpiry struct {
+	TokenExp   time.Duration `json:"token_exp"`
+	RefreshExp time.Duration `json:"refresh_exp"`
+}
+
+// Parse the setting text value token_exp|refresh_exp (minutes), either can be empty
+func ParseTokenExpiry(s SettingTable) (TokenExpiry, error) {
+	var exp TokenExpiry
+	parsed := strings.Split(s.TextValue, SETTING_TOKEN_DELIMITER)
+	if len(parsed) != 2 {
+		return exp, fmt.Errorf("%s %s: expected token_exp|refresh_exp in minutes, got %q", s.Category, s.SettingKey, s.TextValue)
+	}
+	for i, part := range parsed {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		minutes, err := strconv.Atoi(part)
+		if err != nil || minutes < 0 {
+			return exp, fmt.Errorf("%s %s: invalid minutes %q", s.Category, s.SettingKey, part)
+		}
+		if i == 0 {
+			exp.TokenExp = time.Duration(minutes) * time.Minute
+		} else {
+			exp.RefreshExp = time.Duration(minutes) * time.Minute
+		}
+	}
+	return exp, nil
+}
+
+// TokenLifetime is the expiry of the access and refresh token for the user, wrong format is skipped
+func (n SureSQLNode) TokenLifetime(username, role string) (time.Duration, time.Duration) {
+	tokenExp, refreshExp := n.Config.TokenExp, n.Config.RefreshExp
+	if tokenExp <= 0 {
+		tokenExp = DEFAULT_TOKEN_EXPIRES_MINUTES
+	}
+	if refreshExp <= 0 {
+		refreshExp = DEFAULT_REFRESH_EXPIRES_MINUTES
+	}
+	// role first, so the user overwrites it
+	for _, o := range []struct{ category, key string }{
+		{SETTING_CATEGORY_TOKEN_ROLE, role},
+		{SETTING_CATEGORY_TOKEN_USER, username},
+	} {
+		if o.key == "" {
+			continue
+		}
+		s, ok := n.Settings.SettingExist(o.category, o.key)
+		if !ok {
+			continue
+		}
+		exp, err := ParseTokenExpiry(s)
+		if err != nil {
+			continue
+		}
+		if exp.TokenExp > 0 {
+			tokenExp = exp.TokenExp
+		}
+		if exp.RefreshExp > 0 {
+			refreshExp = exp.RefreshExp
+		}
+	}
+	return tokenExp, refreshExp
+}
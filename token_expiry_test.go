@@ -0,0 +1,41 @@
+package suresql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTokenExpiry(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TokenExpiry
+		wantErr bool
+	}{
+		{value: "30|1440", want: TokenExpiry{TokenExp: 30 * time.Minute, RefreshExp: 1440 * time.Minute}},
+		{value: " 15 | ", want: TokenExpiry{TokenExp: 15 * time.Minute}},
+		{value: "|60", want: TokenExpiry{RefreshExp: 60 * time.Minute}},
+		{value: "|", want: TokenExpiry{}},
+		{value: "0|0", want: TokenExpiry{}},
+		{value: "30", wantErr: true},
+		{value: "30|60|90", wantErr: true},
+		{value: "-5|60", wantErr: true},
+		{value: "30|1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTokenExpiry(SettingTable{Category: SETTING_CATEGORY_TOKEN_USER, SettingKey: "john", TextValue: tt.value})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}